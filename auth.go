@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const redacted = "<redacted>"
+
+// Secret is a value that is read from a file or an environment variable, so it
+// does not have to be put in the configuration itself. A plain string is
+// accepted as an inline value too. Inline values are never marshalled.
+type Secret struct {
+	Value string `json:"value,omitempty"`
+	File  string `json:"file,omitempty"`
+	Env   string `json:"env,omitempty"`
+}
+
+func (s *Secret) IsSet() bool {
+	return s != nil && (s.Value != "" || s.File != "" || s.Env != "")
+}
+
+func (s *Secret) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	set := 0
+	for _, v := range []string{s.Value, s.File, s.Env} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return errors.New("a secret should have only one of value, file or env")
+	}
+
+	return nil
+}
+
+// Resolve returns the value of the secret. Files and environment variables are
+// read every time, so rotated secrets are picked up without a restart.
+func (s *Secret) Resolve() (string, error) {
+	switch {
+	case s == nil:
+		return "", nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("could not read secret: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case s.Env != "":
+		value, present := os.LookupEnv(s.Env)
+		if !present {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return value, nil
+	default:
+		return s.Value, nil
+	}
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s.File == "" && s.Env == "" {
+		return json.Marshal(redacted)
+	}
+
+	return json.Marshal(struct {
+		File string `json:"file,omitempty"`
+		Env  string `json:"env,omitempty"`
+	}{File: s.File, Env: s.Env})
+}
+
+func (s *Secret) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err == nil {
+		*s = Secret{Value: value}
+		return nil
+	}
+
+	type plain Secret
+	return json.Unmarshal(b, (*plain)(s))
+}
+
+// RepositoryAuth contains the credentials of a private repository. Basic auth
+// and a bearer token are mutually exclusive, headers are always added.
+type RepositoryAuth struct {
+	Username string            `json:"username,omitempty"`
+	Password *Secret           `json:"password,omitempty"`
+	Token    *Secret           `json:"token,omitempty"`
+	Headers  map[string]Secret `json:"headers,omitempty"`
+}
+
+func (a *RepositoryAuth) Validate() error {
+	if a == nil {
+		return nil
+	}
+
+	if a.Username != "" && a.Token.IsSet() {
+		return errors.New("auth should not contain both a username and a token")
+	}
+
+	if a.Password.IsSet() && a.Username == "" {
+		return errors.New("auth contains a password without a username")
+	}
+
+	for _, s := range []*Secret{a.Password, a.Token} {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for name, s := range a.Headers {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// BasicAuth returns the username and password, if any.
+func (a *RepositoryAuth) BasicAuth() (string, string, bool, error) {
+	if a == nil || a.Username == "" {
+		return "", "", false, nil
+	}
+
+	password, err := a.Password.Resolve()
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return a.Username, password, true, nil
+}
+
+// Apply adds the credentials to the request.
+func (a *RepositoryAuth) Apply(req *http.Request) error {
+	if a == nil {
+		return nil
+	}
+
+	for name, s := range a.Headers {
+		value, err := s.Resolve()
+		if err != nil {
+			return fmt.Errorf("header %s: %w", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	username, password, ok, err := a.BasicAuth()
+	if err != nil {
+		return err
+	}
+	if ok {
+		req.SetBasicAuth(username, password)
+		return nil
+	}
+
+	if a.Token.IsSet() {
+		token, err := a.Token.Resolve()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
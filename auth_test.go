@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestSecret_Resolve(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	_ = os.WriteFile(file, []byte("from-file\n"), 0600)
+	_ = os.Setenv(Existing, "from-env")
+
+	for _, test := range []struct {
+		secret   *Secret
+		expected string
+	}{
+		{nil, ""},
+		{&Secret{Value: "inline"}, "inline"},
+		{&Secret{File: file}, "from-file"},
+		{&Secret{Env: Existing}, "from-env"},
+	} {
+		value, err := test.secret.Resolve()
+		Equals(err, nil, t)
+		Equals(value, test.expected, t)
+	}
+}
+
+func TestSecret_Resolve_Missing(t *testing.T) {
+	_, err := (&Secret{Env: NonExisting}).Resolve()
+	ErrorsEqual(err, errors.New("environment variable NON_EXISTING_ENVIRONMENT_VARIABLE is not set"), t)
+
+	_, err = (&Secret{File: filepath.Join(t.TempDir(), "missing")}).Resolve()
+	Equals(err != nil, true, t)
+}
+
+func TestSecret_UnmarshalJSON(t *testing.T) {
+	var auth RepositoryAuth
+	err := yaml.Unmarshal([]byte(`
+username: user
+password: inline
+headers:
+  X-Api-Key:
+    env: API_KEY
+`), &auth)
+
+	Equals(err, nil, t)
+	Equals(auth.Password.Value, "inline", t)
+	Equals(auth.Headers["X-Api-Key"].Env, "API_KEY", t)
+}
+
+func TestSecret_MarshalJSON(t *testing.T) {
+	auth := RepositoryAuth{
+		Username: "user",
+		Password: &Secret{Value: "s3cr3t"},
+		Headers:  map[string]Secret{"X-Api-Key": {File: "/run/secrets/key"}},
+	}
+
+	data, err := yaml.Marshal(auth)
+
+	Equals(err, nil, t)
+	Equals(string(data), `headers:
+  X-Api-Key:
+    file: /run/secrets/key
+password: <redacted>
+username: user
+`, t)
+}
+
+func TestSecret_Validate(t *testing.T) {
+	Equals((&Secret{Env: "A"}).Validate(), nil, t)
+	ErrorsEqual((&Secret{Env: "A", File: "b"}).Validate(), errors.New("a secret should have only one of value, file or env"), t)
+}
+
+func TestRepositoryAuth_Validate(t *testing.T) {
+	var nilAuth *RepositoryAuth
+	Equals(nilAuth.Validate(), nil, t)
+	Equals((&RepositoryAuth{Username: "user", Password: &Secret{Env: "A"}}).Validate(), nil, t)
+	ErrorsEqual((&RepositoryAuth{Username: "user", Token: &Secret{Value: "a"}}).Validate(), errors.New("auth should not contain both a username and a token"), t)
+	ErrorsEqual((&RepositoryAuth{Password: &Secret{Value: "a"}}).Validate(), errors.New("auth contains a password without a username"), t)
+}
+
+func TestRepositoryAuth_Apply_Basic(t *testing.T) {
+	req := httptest.NewRequest("GET", "/index.yaml", nil)
+
+	err := (&RepositoryAuth{Username: "user", Password: &Secret{Value: "pass"}}).Apply(req)
+
+	Equals(err, nil, t)
+	username, password, ok := req.BasicAuth()
+	Equals(ok, true, t)
+	Equals(username, "user", t)
+	Equals(password, "pass", t)
+}
+
+func TestRepositoryAuth_Apply_TokenAndHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/index.yaml", nil)
+
+	err := (&RepositoryAuth{
+		Token:   &Secret{Value: "token"},
+		Headers: map[string]Secret{"X-Api-Key": {Value: "key"}},
+	}).Apply(req)
+
+	Equals(err, nil, t)
+	Equals(req.Header.Get("Authorization"), "Bearer token", t)
+	Equals(req.Header.Get("X-Api-Key"), "key", t)
+}
+
+func TestConfig_String_DoesNotContainSecrets(t *testing.T) {
+	c := DefaultConfig()
+	c.Repositories = []Repository{
+		{
+			URL:    "https://example.com/index.yaml",
+			Charts: []Chart{{Name: "chart"}},
+			Auth: &RepositoryAuth{
+				Username: "user",
+				Password: &Secret{Value: "s3cr3t-password"},
+				Headers:  map[string]Secret{"X-Api-Key": {Value: "s3cr3t-key"}},
+			},
+		},
+	}
+
+	Equals(strings.Contains(c.String(), "s3cr3t"), false, t)
+}
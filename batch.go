@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	BatchModeCycle  = "cycle"
+	BatchModeWindow = "window"
+)
+
+// batchBucket holds the reports of the batch that is being collected, so they
+// survive restarts.
+const batchBucket = "batch"
+const batchKey = "pending"
+
+// BatchConfig configures batching of reports. In cycle mode the reports of a
+// check cycle are sent together once the cycle completed, in window mode the
+// reports found within Window after the first one are sent together.
+type BatchConfig struct {
+	Mode   string   `json:"mode,omitempty"`
+	Window Duration `json:"window,omitempty"`
+}
+
+func (b BatchConfig) Validate() error {
+	switch b.Mode {
+	case "", BatchModeCycle:
+		return nil
+	case BatchModeWindow:
+		if b.Window <= 0 {
+			return errors.New("batch mode window requires a window")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown batch mode %s", b.Mode)
+	}
+}
+
+// Batcher collects reports and enqueues them as a single notification.
+// Without a batch mode every report is enqueued right away.
+type Batcher struct {
+	config BatchConfig
+	store  Store
+	outbox Enqueuer
+
+	mutex   sync.Mutex
+	pending []Report
+	// scheduled is set whilst the flush at the end of the window is waiting.
+	scheduled bool
+	afterFunc func(time.Duration, func())
+}
+
+func NewBatcher(config BatchConfig, store Store, outbox Enqueuer) *Batcher {
+	b := &Batcher{
+		config: config,
+		store:  store,
+		outbox: outbox,
+		afterFunc: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+	}
+
+	_, err := store.Get(batchBucket, batchKey, &b.pending)
+	if err != nil {
+		log.Println("Could not load the pending batch", err)
+	}
+	if len(b.pending) > 0 && config.Mode == BatchModeWindow {
+		b.scheduled = true
+		b.afterFunc(config.Window.Duration(), b.Flush)
+	}
+
+	return b
+}
+
+func (b *Batcher) Add(report Report) error {
+	if b.config.Mode == "" {
+		return b.outbox.Enqueue(Notification{Reports: []Report{report}})
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.pending = append(b.pending, report)
+	if b.config.Mode == BatchModeWindow && !b.scheduled {
+		b.scheduled = true
+		b.afterFunc(b.config.Window.Duration(), b.Flush)
+	}
+
+	return b.store.Put(batchBucket, batchKey, b.pending)
+}
+
+// CycleCompleted sends the batch in cycle mode. Reports that were pending
+// since before a restart are sent along.
+func (b *Batcher) CycleCompleted() {
+	if b.config.Mode == BatchModeCycle || (b.config.Mode == "" && b.hasPending()) {
+		b.Flush()
+	}
+}
+
+func (b *Batcher) hasPending() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.pending) > 0
+}
+
+// Flush enqueues the pending reports as a single notification.
+func (b *Batcher) Flush() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.scheduled = false
+	if len(b.pending) == 0 {
+		return
+	}
+
+	err := b.outbox.Enqueue(Notification{Reports: b.pending})
+	if err != nil {
+		log.Println("Could not enqueue the batch of", len(b.pending), "reports", err)
+		return
+	}
+
+	b.pending = nil
+	err = b.store.Delete(batchBucket, batchKey)
+	if err != nil {
+		log.Println("Could not remove the pending batch", err)
+	}
+}
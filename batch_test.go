@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatchConfig_Validate(t *testing.T) {
+	Equals(BatchConfig{}.Validate(), nil, t)
+	Equals(BatchConfig{Mode: BatchModeCycle}.Validate(), nil, t)
+	Equals(BatchConfig{Mode: BatchModeWindow, Window: Duration(time.Minute)}.Validate(), nil, t)
+	ErrorsEqual(BatchConfig{Mode: BatchModeWindow}.Validate(), errors.New("batch mode window requires a window"), t)
+	ErrorsEqual(BatchConfig{Mode: "daily"}.Validate(), errors.New("unknown batch mode daily"), t)
+}
+
+func TestBatcher_NoBatching(t *testing.T) {
+	store := NewMemoryStore()
+	notifier := &fakeNotifier{name: "team"}
+	outbox, _ := testOutbox(store, notifier)
+	batcher := NewBatcher(BatchConfig{}, store, outbox)
+
+	_ = batcher.Add(testReport())
+	_ = batcher.Add(testReport())
+
+	Equals(outbox.Pending(), 1, t)
+}
+
+func TestBatcher_Cycle(t *testing.T) {
+	store := NewMemoryStore()
+	notifier := &fakeNotifier{name: "team"}
+	outbox, _ := testOutbox(store, notifier)
+	batcher := NewBatcher(BatchConfig{Mode: BatchModeCycle}, store, outbox)
+
+	other := testReport()
+	other.Chart = "other"
+	_ = batcher.Add(testReport())
+	_ = batcher.Add(other)
+	Equals(outbox.Pending(), 0, t)
+
+	batcher.CycleCompleted()
+	outbox.Deliver()
+
+	Equals(len(notifier.notifications), 1, t)
+	Equals(len(notifier.notifications[0].Reports), 2, t)
+
+	batcher.CycleCompleted()
+	Equals(outbox.Pending(), 0, t)
+}
+
+func TestBatcher_Window(t *testing.T) {
+	store := NewMemoryStore()
+	notifier := &fakeNotifier{name: "team"}
+	outbox, _ := testOutbox(store, notifier)
+	batcher := NewBatcher(BatchConfig{Mode: BatchModeWindow, Window: Duration(time.Minute)}, store, outbox)
+	var flushes []func()
+	batcher.afterFunc = func(d time.Duration, f func()) {
+		Equals(d, time.Minute, t)
+		flushes = append(flushes, f)
+	}
+
+	_ = batcher.Add(testReport())
+	_ = batcher.Add(testReport())
+	batcher.CycleCompleted()
+	Equals(outbox.Pending(), 0, t)
+	Equals(len(flushes), 1, t)
+
+	flushes[0]()
+	outbox.Deliver()
+
+	Equals(len(notifier.notifications), 1, t)
+	Equals(len(notifier.notifications[0].Reports), 2, t)
+}
+
+func TestBatcher_SurvivesRestart(t *testing.T) {
+	store := NewMemoryStore()
+	outbox, _ := testOutbox(store, &fakeNotifier{name: "team"})
+	batcher := NewBatcher(BatchConfig{Mode: BatchModeCycle}, store, outbox)
+	_ = batcher.Add(testReport())
+
+	batcher = NewBatcher(BatchConfig{Mode: BatchModeCycle}, store, outbox)
+	batcher.CycleCompleted()
+
+	Equals(outbox.Pending(), 1, t)
+}
+
+func TestCheckRepositoriesForUpdates_EndOfCycle(t *testing.T) {
+	toCheck := make(chan *RepositoryContents, 1)
+	reporter := &fakeReporter{}
+
+	toCheck <- nil
+	close(toCheck)
+	checkRepositoriesForUpdates(NewMemoryStore(), toCheck, reporter)
+
+	Equals(reporter.cycles, 1, t)
+}
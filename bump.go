@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+)
+
+// Bump is the kind of change between two versions, ordered by significance.
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return "none"
+	}
+}
+
+func ParseBump(s string) (Bump, error) {
+	for _, b := range []Bump{BumpNone, BumpPatch, BumpMinor, BumpMajor} {
+		if b.String() == s {
+			return b, nil
+		}
+	}
+
+	return BumpNone, fmt.Errorf("unknown bump %s, expected major, minor, patch or none", s)
+}
+
+// BumpBetween returns the most significant change needed to go from one version
+// to the other. Any other difference, such as a new prerelease, counts as a
+// patch. It returns BumpNone when to is not newer than from.
+func BumpBetween(from, to *semver.Version) Bump {
+	if from == nil || to == nil || !to.GreaterThan(from) {
+		return BumpNone
+	}
+
+	switch {
+	case to.Major() != from.Major():
+		return BumpMajor
+	case to.Minor() != from.Minor():
+		return BumpMinor
+	default:
+		return BumpPatch
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+func TestBumpBetween(t *testing.T) {
+	tests := []struct {
+		from, to string
+		bump     Bump
+	}{
+		{"1.0.0", "2.0.0", BumpMajor},
+		{"1.0.0", "1.1.0", BumpMinor},
+		{"1.0.0", "1.0.1", BumpPatch},
+		{"1.0.0-rc.1", "1.0.0", BumpPatch},
+		{"1.0.0", "1.0.0", BumpNone},
+		{"2.0.0", "1.0.0", BumpNone},
+	}
+
+	for _, test := range tests {
+		bump := BumpBetween(semver.MustParse(test.from), semver.MustParse(test.to))
+		if bump != test.bump {
+			t.Errorf("bump between %s and %s should be %s, got %s", test.from, test.to, test.bump, bump)
+		}
+	}
+}
+
+func TestBumpBetween_Nil(t *testing.T) {
+	Equals(BumpBetween(nil, semver.MustParse("1.0.0")), BumpNone, t)
+}
+
+func TestParseBump(t *testing.T) {
+	for _, b := range []Bump{BumpNone, BumpPatch, BumpMinor, BumpMajor} {
+		parsed, err := ParseBump(b.String())
+		Equals(err, nil, t)
+		Equals(parsed, b, t)
+	}
+
+	_, err := ParseBump("huge")
+	Equals(err.Error(), "unknown bump huge, expected major, minor, patch or none", t)
+}
+
+func TestReport_Bump(t *testing.T) {
+	Equals(testReport().Bump(), BumpMinor, t)
+}
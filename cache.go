@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"reflect"
+	"sort"
+)
+
+// indexCacheBucket holds a CachedIndex for every index repository, keyed by
+// repository URL.
+const indexCacheBucket = "index_cache"
+
+// CachedIndex is what is remembered of an index to avoid downloading and
+// parsing it again when it did not change. Only the entries of the monitored
+// charts are kept.
+type CachedIndex struct {
+	ETag         string                `json:"etag,omitempty"`
+	LastModified string                `json:"last_modified,omitempty"`
+	Generated    string                `json:"generated,omitempty"`
+	Charts       []ChartName           `json:"charts"`
+	Entries      map[ChartName][]Entry `json:"entries"`
+}
+
+func (ci CachedIndex) Contents(url string) *RepositoryContents {
+	entries := make(map[ChartName][]Entry, len(ci.Entries))
+	for chart, e := range ci.Entries {
+		entries[chart] = append([]Entry(nil), e...)
+	}
+
+	return &RepositoryContents{
+		URL:       url,
+		Entries:   entries,
+		Generated: ci.Generated,
+	}
+}
+
+// IndexCache persists CachedIndexes in a Store. A nil IndexCache caches
+// nothing.
+type IndexCache struct {
+	store Store
+}
+
+func NewIndexCache(store Store) *IndexCache {
+	return &IndexCache{store: store}
+}
+
+// Get returns the cached index of the repository, provided it was cached for
+// the same charts that are monitored now.
+func (c *IndexCache) Get(repo Repository) (CachedIndex, bool) {
+	var cached CachedIndex
+	if c == nil {
+		return cached, false
+	}
+
+	found, err := c.store.Get(indexCacheBucket, repo.URL, &cached)
+	if err != nil {
+		log.Println("Could not load cached index of", repo.URL, err)
+		return cached, false
+	}
+
+	if !found || !reflect.DeepEqual(cached.Charts, chartNames(repo.Charts)) {
+		return cached, false
+	}
+
+	return cached, true
+}
+
+func (c *IndexCache) Put(repo Repository, cached CachedIndex) {
+	if c == nil {
+		return
+	}
+
+	cached.Charts = chartNames(repo.Charts)
+	err := c.store.Put(indexCacheBucket, repo.URL, cached)
+	if err != nil {
+		log.Println("Could not cache index of", repo.URL, err)
+	}
+}
+
+func chartNames(charts []Chart) []ChartName {
+	names := make([]ChartName, len(charts))
+	for i, c := range charts {
+		names[i] = c.Name
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
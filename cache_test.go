@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestIndexCache_Get_DifferentCharts(t *testing.T) {
+	cache := NewIndexCache(NewMemoryStore())
+	repo := Repository{URL: "https://example.com/index.yaml", Charts: []Chart{{Name: "a"}}}
+	cache.Put(repo, CachedIndex{ETag: "etag"})
+
+	cached, ok := cache.Get(repo)
+	Equals(ok, true, t)
+	Equals(cached.ETag, "etag", t)
+
+	repo.Charts = append(repo.Charts, Chart{Name: "b"})
+	_, ok = cache.Get(repo)
+	Equals(ok, false, t)
+}
+
+func TestIndexCache_Nil(t *testing.T) {
+	var cache *IndexCache
+	cache.Put(Repository{}, CachedIndex{})
+
+	_, ok := cache.Get(Repository{})
+	Equals(ok, false, t)
+}
+
+func TestFetchRepositoryContents_ConditionalGet(t *testing.T) {
+	requests, notModified := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+
+	cache := NewIndexCache(NewMemoryStore())
+	repo := Repository{URL: server.URL + "/fakechart.yaml", Charts: []Chart{{Name: "test"}}}
+
+	first, err := fetchRepositoryContents(context.Background(), cache, repo)
+	Equals(err, nil, t)
+	second, err := fetchRepositoryContents(context.Background(), cache, repo)
+	Equals(err, nil, t)
+
+	Equals(requests, 2, t)
+	Equals(notModified, 1, t)
+	MapsEqual(versionStrings(second.Versions["test"]), versionStrings(first.Versions["test"]), t)
+	Equals(second.Generated, "2022-08-02T14:06:28.079863412Z", t)
+}
+
+func TestFetchRepositoryContents_SameGenerated(t *testing.T) {
+	index, _ := os.ReadFile("fakechart.yaml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(index)
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore()
+	cache := NewIndexCache(store)
+	repo := Repository{URL: server.URL + "/fakechart.yaml", Charts: []Chart{{Name: "test"}}}
+	_, _ = fetchRepositoryContents(context.Background(), cache, repo)
+
+	// The cached entries are returned for an index that was not generated
+	// again.
+	cached, _ := cache.Get(repo)
+	cached.Entries["test"] = []Entry{{Version: "1.0.0"}}
+	cache.Put(repo, cached)
+
+	contents, err := fetchRepositoryContents(context.Background(), cache, repo)
+
+	Equals(err, nil, t)
+	MapsEqual(versionStrings(contents.Versions["test"]), []string{"1.0.0"}, t)
+}
@@ -1,152 +1,157 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"github.com/Masterminds/semver"
-	"gopkg.in/yaml.v2"
-	"io"
+	"context"
 	"log"
-	"net/http"
-	"sort"
-	"strings"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		code := runCheck(ctx, os.Args[2:], os.Stdout)
+		stop()
+		os.Exit(code)
+	}
+
 	config := getConfig()
 	fixRepoURLS(config)
 	log.Println(config)
+	err := config.BuildHTTPClients()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	store, err := OpenStore(config.State)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer store.Close()
+
+	notifiers, err := config.BuildNotifiers()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	cache := NewIndexCache(store)
+	health := NewHealth(config.LivenessMaxAge())
+	startServer(config.ListenAddress, health)
 
 	repositoriesToCheckForUpdates := make(chan *RepositoryContents)
-	versionsToReport := make(chan Report)
-	go checkRepositoriesForUpdates(repositoriesToCheckForUpdates, versionsToReport)
-	go reportNewVersions(config, versionsToReport)
+	windows, err := config.BuildDeliveryWindows()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outbox := NewOutbox(store, notifiers, windows)
+	router, err := config.BuildRouter()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	dispatcher := NewDispatcher(outbox, notifiers, router)
+	digests, err := NewDigests(config, store, outbox, dispatcher)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	batcher := NewBatcher(config.Batch, store, dispatcher)
+	// The store is closed when main returns, so everything that uses it is
+	// waited for when shutting down.
+	var running sync.WaitGroup
+	running.Add(3)
+	go func() {
+		defer running.Done()
+		outbox.Run(ctx)
+	}()
+	go func() {
+		defer running.Done()
+		digests.Run(ctx)
+	}()
+	reporter := &VersionReporter{config: config, batcher: batcher, digests: digests}
+	go func() {
+		defer running.Done()
+		checkRepositoriesForUpdates(store, repositoriesToCheckForUpdates, reporter)
+	}()
+
+	// Cycles run one at a time on this goroutine, as the end of a cycle
+	// completes the batch that it collected. A slow cycle delays the next one
+	// instead of overlapping it.
+	checkCycle := func() {
+		fetchAllRepositories(ctx, config, cache, repositoriesToCheckForUpdates)
+		repositoriesToCheckForUpdates <- nil
+		health.CycleCompleted()
+	}
 
 	ticker := time.NewTicker(config.CheckInterval.Duration())
-	go sendStartInfo(config)
-	go fetchAllRepositories(config, repositoriesToCheckForUpdates)
+	go sendStartInfo(config, notifiers)
+	checkCycle()
 	for {
 		select {
 		case <-ticker.C:
-			fetchAllRepositories(config, repositoriesToCheckForUpdates)
+			checkCycle()
+		case <-ctx.Done():
+			log.Println("Shutting down")
+			close(repositoriesToCheckForUpdates)
+			running.Wait()
+			return
 		}
 	}
 }
 
-func sendStartInfo(config Config) {
+func sendStartInfo(config Config, notifiers []Notifier) {
 	if !config.ReportStart {
 		return
 	}
-	s := fmt.Sprintf("%s :: %s\n%s", time.Now().Format("2006-01-02 15:04:05"), "Helmchart monitor started", config)
-	sendMessageToSlack(config, Message{Text: s})
-}
 
-func fetchAllRepositories(config Config, repositoriesToCheckForUpdates chan *RepositoryContents) {
-	for _, repo := range config.Repositories {
-		repoContents, err := fetchRepositoryContents(repo)
+	var template *MessageTemplate
+	if config.StartTemplate != "" {
+		var err error
+		template, err = ParseMessageTemplate(config.StartTemplate)
 		if err != nil {
-			log.Println("Could not fetch contents for", repo.URL, err)
-			continue
-		}
-
-		repositoriesToCheckForUpdates <- repoContents
-	}
-}
-
-type Report struct {
-	Repository string
-	Chart      ChartName
-	NewVersion *semver.Version
-}
-
-func checkRepositoriesForUpdates(toCheck <-chan *RepositoryContents, toReport chan<- Report) {
-	highestVersions := make(map[string]map[ChartName]*semver.Version)
-	for repo := range toCheck {
-		log.Println("Checking:", repo.URL)
-		if highestVersions[repo.URL] == nil {
-			highestVersions[repo.URL] = make(map[ChartName]*semver.Version)
-		}
-
-		for chartName, versions := range repo.Versions {
-			sort.Sort(versions)
-			highestVersion := versions[len(versions)-1]
-
-			currentVersion, ok := highestVersions[repo.URL][chartName]
-			if !ok {
-				highestVersions[repo.URL][chartName] = highestVersion
-				continue
-			}
-
-			if currentVersion.LessThan(highestVersion) {
-				highestVersions[repo.URL][chartName] = highestVersion
-				toReport <- Report{
-					Repository: repo.URL,
-					Chart:      chartName,
-					NewVersion: highestVersion,
-				}
-			}
+			log.Println("Could not report start", err)
+			return
 		}
-
 	}
-}
-
-type Message struct {
-	Text string `json:"text"`
-}
 
-func reportNewVersions(config Config, toReport <-chan Report) {
-	for report := range toReport {
-		msg := Message{
-			Text: fmt.Sprintf("Chart *%s* in repo %s updated to version *%s*", report.Chart, report.Repository, report.NewVersion),
-		}
-		dependees := config.DependeesForChart(report.Repository, report.Chart)
-		if len(dependees) > 0 {
-			msg.Text = fmt.Sprintln(msg.Text, "\nYou might want to check:", strings.Join(dependees, ", "))
-		}
-
-		sendMessageToSlack(config, msg)
-		log.Println(report)
+	s, err := template.RenderStart(NewStartInfo(config, time.Now()))
+	if err != nil {
+		log.Println("Could not report start", err)
+		return
 	}
-}
 
-func sendMessageToSlack(config Config, msg Message) {
-	data, _ := json.Marshal(msg)
-	response, err := http.Post(config.WebhookURL, http.DetectContentType(data), bytes.NewReader(data))
+	err = NotifyAll(notifiers, NewTextNotification(s))
 	if err != nil {
-		log.Println(err)
-	}
-	if response.StatusCode > 300 {
-		log.Println("Weird response code whilst reporting", response.StatusCode)
+		log.Println("Could not report start", err)
 	}
 }
 
-func fetchRepositoryContents(repo Repository) (*RepositoryContents, error) {
-	resp, err := http.Get(repo.URL)
-	if err != nil {
-		return nil, err
-	}
+// VersionReporter hands the reports to the batcher, which dispatches them to
+// the notifiers, or to their digest for charts with a digest schedule. Either
+// way the report is stored before Report returns.
+type VersionReporter struct {
+	config  Config
+	batcher *Batcher
+	digests *Digests
+}
 
-	if resp.StatusCode > 400 {
-		return nil, errors.New("Unable to fetch: " + repo.URL)
-	}
+func (v *VersionReporter) Report(report Report) error {
+	report.Dependees = v.config.DependeesForChart(report.Repository, report.Chart)
+	report.RepositoryName = v.config.RepositoryName(report.Repository)
+	log.Println(report)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if key := chartDigestKey(report.Repository, report.Chart); v.digests.Has(key) {
+		return v.digests.Add(key, report)
 	}
 
-	repoContents := RepositoryContents{URL: repo.URL}
-	err = yaml.Unmarshal(body, &repoContents)
-	if err != nil {
-		return nil, err
-	}
+	return v.batcher.Add(report)
+}
 
-	repoContents.FilterCharts(repo.Charts)
-	repoContents.EntriesToVersions()
-	return &repoContents, nil
+func (v *VersionReporter) CycleCompleted() {
+	v.batcher.CycleCompleted()
 }
 
 func getConfig() Config {
@@ -161,6 +166,10 @@ func getConfig() Config {
 
 func fixRepoURLS(config Config) {
 	for i, repo := range config.Repositories {
+		if IsOCIRepository(repo.URL) {
+			continue
+		}
+
 		repoIndex := "/index.yaml"
 		extension := ".yaml"
 		if repo.URL[len(repo.URL)-len(extension):] != extension {
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver"
+)
+
+// Exit codes of the check command.
+const (
+	CheckUpToDate = 0
+	CheckOutdated = 1
+	CheckFailed   = 2
+)
+
+// runCheck implements the check command, which fetches all repositories once
+// and compares the latest versions with the versions pinned by the dependees.
+func runCheck(ctx context.Context, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(out)
+	configFile := flags.String("config", "config.yml", "configuration file to read")
+	failOn := flags.String("fail-on", BumpMajor.String(), "exit with a non-zero code when a pin is outdated by this bump or more: major, minor or patch, none never fails")
+	err := flags.Parse(args)
+	if err != nil {
+		return CheckFailed
+	}
+
+	threshold, err := ParseBump(*failOn)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return CheckFailed
+	}
+
+	config := DefaultConfig().FromFile(*configFile).FromEnvironment()
+	err = config.ValidateRepositories()
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return CheckFailed
+	}
+	fixRepoURLS(config)
+
+	return check(ctx, config, threshold, out)
+}
+
+func check(ctx context.Context, config Config, threshold Bump, out io.Writer) int {
+	result := CheckUpToDate
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tCHART\tLATEST\tDEPENDEE\tPINNED\tBUMP")
+
+	for _, repo := range config.Repositories {
+		contents, err := fetchWithTimeout(ctx, config, nil, repo)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t\t\t\t\tcould not fetch: %s\n", repo.URL, err)
+			result = CheckFailed
+			continue
+		}
+
+		for _, chart := range repo.Charts {
+			latest := latestVersion(contents.Versions[chart.Name])
+			if latest == nil {
+				fmt.Fprintf(w, "%s\t%s\t-\t\t\tno versions found\n", repo.URL, chart.Name)
+				result = CheckFailed
+				continue
+			}
+
+			if len(chart.Pins) == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\n", repo.URL, chart.Name, latest)
+				continue
+			}
+
+			for _, dependee := range sortedKeys(chart.Pins) {
+				pinned, err := semver.NewVersion(chart.Pins[dependee])
+				if err != nil {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\tinvalid pin: %s\n", repo.URL, chart.Name, latest, dependee, chart.Pins[dependee], err)
+					result = CheckFailed
+					continue
+				}
+				bump := BumpBetween(pinned, latest)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", repo.URL, chart.Name, latest, dependee, pinned, bump)
+
+				if threshold != BumpNone && bump >= threshold && result == CheckUpToDate {
+					result = CheckOutdated
+				}
+			}
+		}
+	}
+
+	_ = w.Flush()
+	return result
+}
+
+func latestVersion(versions semver.Collection) *semver.Version {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	return versions[len(versions)-1]
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
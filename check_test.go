@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func fakeIndexServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+}
+
+func checkConfig(url string, pins map[string]string) Config {
+	return Config{
+		Repositories: []Repository{
+			{
+				URL:    url + "/fakechart.yaml",
+				Charts: []Chart{{Name: "test", Pins: pins}},
+			},
+		},
+	}
+}
+
+func TestCheck_UpToDate(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	result := check(context.Background(), checkConfig(server.URL, map[string]string{"service": "4.5.10"}), BumpPatch, out)
+
+	Equals(result, CheckUpToDate, t)
+	Equals(strings.Contains(out.String(), "4.5.10  service   4.5.10  none"), true, t)
+}
+
+func TestCheck_OutdatedBelowThreshold(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	result := check(context.Background(), checkConfig(server.URL, map[string]string{"service": "4.5.2"}), BumpMinor, &bytes.Buffer{})
+
+	Equals(result, CheckUpToDate, t)
+}
+
+func TestCheck_Outdated(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	result := check(context.Background(), checkConfig(server.URL, map[string]string{"service": "4.5.2", "other": "4.5.10"}), BumpPatch, out)
+
+	Equals(result, CheckOutdated, t)
+	Equals(strings.Contains(out.String(), "service   4.5.2   patch"), true, t)
+}
+
+func TestCheck_NoneNeverFails(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	result := check(context.Background(), checkConfig(server.URL, map[string]string{"service": "1.0.0"}), BumpNone, &bytes.Buffer{})
+
+	Equals(result, CheckUpToDate, t)
+}
+
+func TestCheck_FetchFailure(t *testing.T) {
+	server := fakeIndexServer()
+	server.Close()
+
+	result := check(context.Background(), checkConfig(server.URL, nil), BumpMajor, &bytes.Buffer{})
+
+	Equals(result, CheckFailed, t)
+}
+
+func TestCheck_InvalidPin(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	result := check(context.Background(), checkConfig(server.URL, map[string]string{"service": "latest"}), BumpMajor, out)
+
+	Equals(result, CheckFailed, t)
+	Equals(strings.Contains(out.String(), "invalid pin"), true, t)
+}
+
+func TestRunCheck_InvalidFailOn(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	result := runCheck(context.Background(), []string{"--fail-on", "huge"}, out)
+
+	Equals(result, CheckFailed, t)
+	Equals(out.String(), "unknown bump huge, expected major, minor, patch or none\n", t)
+}
@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver"
+
 	"sigs.k8s.io/yaml"
 )
 
@@ -15,10 +20,51 @@ const ENV_Repositories = "CVM_REPOSITORIES"
 const ENV_WebhookURL = "CVM_WEBHOOK_URL"
 const ENV_ReportStart = "CVM_REPORT_START"
 const ENV_CheckInterval = "CVM_CHECK_INTERVAL"
+const ENV_Notifiers = "CVM_NOTIFIERS"
+const ENV_ListenAddress = "CVM_LISTEN_ADDRESS"
+const ENV_LivenessIntervals = "CVM_LIVENESS_INTERVALS"
+const ENV_Concurrency = "CVM_CONCURRENCY"
+const ENV_FetchTimeout = "CVM_FETCH_TIMEOUT"
+const ENV_StateType = "CVM_STATE_TYPE"
+const ENV_StatePath = "CVM_STATE_PATH"
+const ENV_Template = "CVM_TEMPLATE"
+const ENV_StartTemplate = "CVM_START_TEMPLATE"
+const ENV_BatchMode = "CVM_BATCH_MODE"
+const ENV_BatchWindow = "CVM_BATCH_WINDOW"
 
 type Repository struct {
+	// Name is used in notifications instead of the URL when it is set.
+	Name   string  `json:"name,omitempty"`
 	URL    string  `json:"url"`
 	Charts []Chart `json:"charts"`
+	// PlainHTTP makes OCI registries be contacted over http instead of https.
+	PlainHTTP bool `json:"plain_http,omitempty"`
+	// Prereleases is the prerelease policy of charts that do not have their own.
+	Prereleases string          `json:"prereleases,omitempty"`
+	Auth        *RepositoryAuth `json:"auth,omitempty"`
+	TLS         *RepositoryTLS  `json:"tls,omitempty"`
+	// Proxy is the URL of the proxy to use, such as http://proxy.example.com:3128
+	Proxy string `json:"proxy,omitempty"`
+
+	// client is reused for every fetch of the repository once it is built.
+	client *http.Client
+}
+
+// ChartsWithDefaults returns the charts of the repository with the settings
+// they inherit from the repository filled in.
+func (r Repository) ChartsWithDefaults() []Chart {
+	charts := make([]Chart, len(r.Charts))
+	for i, c := range r.Charts {
+		if c.Prereleases == "" {
+			c.Prereleases = r.Prereleases
+		}
+		if c.Prereleases == "" {
+			c.Prereleases = PrereleasesIgnore
+		}
+		charts[i] = c
+	}
+
+	return charts
 }
 
 func (r Repository) Validate() error {
@@ -30,23 +76,179 @@ func (r Repository) Validate() error {
 		return fmt.Errorf("repository %s is not configured to monitor any charts", r.URL)
 	}
 
+	if !validPrereleasePolicy(r.Prereleases) {
+		return fmt.Errorf("repository %s has unknown prerelease policy %s", r.URL, r.Prereleases)
+	}
+
+	err := r.Auth.Validate()
+	if err != nil {
+		return fmt.Errorf("repository %s has invalid auth: %w", r.URL, err)
+	}
+
+	err = r.TLS.Validate()
+	if err != nil {
+		return fmt.Errorf("repository %s has invalid tls settings: %w", r.URL, err)
+	}
+
+	if r.Proxy != "" {
+		proxy, err := url.Parse(r.Proxy)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("repository %s has invalid proxy %s", r.URL, r.Proxy)
+		}
+	}
+
+	for _, c := range r.Charts {
+		_, err = c.Constraints()
+		if err != nil {
+			return fmt.Errorf("chart %s in repository %s has an invalid constraint: %w", c.Name, r.URL, err)
+		}
+
+		if !validPrereleasePolicy(c.Prereleases) {
+			return fmt.Errorf("chart %s in repository %s has unknown prerelease policy %s", c.Name, r.URL, c.Prereleases)
+		}
+
+		for dependee, version := range c.Pins {
+			_, err = semver.NewVersion(version)
+			if err != nil {
+				return fmt.Errorf("chart %s in repository %s has an invalid pin for %s: %w", c.Name, r.URL, dependee, err)
+			}
+		}
+
+		if c.Digest != "" {
+			_, err = ParseSchedule(c.Digest)
+			if err != nil {
+				return fmt.Errorf("chart %s in repository %s has an invalid digest: %w", c.Name, r.URL, err)
+			}
+		}
+	}
+
 	return nil
 }
 
 type Chart struct {
 	Name      ChartName `json:"name"`
 	Dependees []string  `json:"dependees"`
+	// Constraint limits the versions that are monitored, ~1.2 or >=3.0 <4.0
+	// for instance.
+	Constraint string `json:"constraint,omitempty"`
+	// Prereleases overrides the prerelease policy of the repository.
+	Prereleases string `json:"prereleases,omitempty"`
+	// Pins contains the version of the chart each dependee currently uses.
+	Pins map[string]string `json:"pins,omitempty"`
+	// Digest is the schedule at which the reports of the chart are sent,
+	// "every monday 09:00 Europe/Amsterdam" for instance, instead of right
+	// away.
+	Digest string `json:"digest,omitempty"`
+}
+
+const (
+	// PrereleasesIgnore drops prereleases, which is the default.
+	PrereleasesIgnore = "ignore"
+	// PrereleasesSeparate tracks prereleases on a separate preview channel.
+	PrereleasesSeparate = "separate"
+	// PrereleasesInclude treats prereleases like stable releases.
+	PrereleasesInclude = "include"
+)
+
+func validPrereleasePolicy(policy string) bool {
+	switch policy {
+	case "", PrereleasesIgnore, PrereleasesSeparate, PrereleasesInclude:
+		return true
+	default:
+		return false
+	}
+}
+
+// Constraints parses the constraint of the chart. It returns nil when the chart
+// has no constraint. Besides the comma separated form of Masterminds/semver,
+// space separated constraints such as >=3.0 <4.0 are accepted too.
+func (c Chart) Constraints() (*semver.Constraints, error) {
+	if strings.TrimSpace(c.Constraint) == "" {
+		return nil, nil
+	}
+
+	return semver.NewConstraint(normalizeConstraint(c.Constraint))
+}
+
+// Allows reports whether the version satisfies the constraint of the chart.
+// Prereleases are checked as the release they precede, so 1.2.5-rc.1 satisfies
+// ~1.2 whereas 1.3.0-rc.1 does not.
+func (c Chart) Allows(version *semver.Version) bool {
+	constraints, err := c.Constraints()
+	if err != nil || constraints == nil {
+		return err == nil
+	}
+
+	if version.Prerelease() != "" {
+		release, err := version.SetPrerelease("")
+		if err != nil {
+			return false
+		}
+		version = &release
+	}
+
+	return constraints.Check(version)
+}
+
+func normalizeConstraint(constraint string) string {
+	alternatives := strings.Split(constraint, "||")
+	for i, alternative := range alternatives {
+		if strings.Contains(alternative, ",") || strings.Contains(alternative, " - ") {
+			continue
+		}
+
+		fields := strings.Fields(alternative)
+		merged := make([]string, 0, len(fields))
+		for j := 0; j < len(fields); j++ {
+			field := fields[j]
+			// Join operators that are separated from their version, as in >= 3.0
+			if strings.Trim(field, "<>=!~^") == "" && j+1 < len(fields) {
+				j++
+				field += fields[j]
+			}
+			merged = append(merged, field)
+		}
+		alternatives[i] = strings.Join(merged, ", ")
+	}
+
+	return strings.Join(alternatives, " || ")
 }
 
 type Config struct {
-	Repositories  []Repository `json:"repositories"`
-	CheckInterval Duration     `json:"check_interval"`
-	WebhookURL    string       `json:"webhook_url"`
-	ReportStart   bool         `json:"report_start"`
+	Repositories  []Repository     `json:"repositories"`
+	CheckInterval Duration         `json:"check_interval"`
+	WebhookURL    string           `json:"webhook_url"`
+	ReportStart   bool             `json:"report_start"`
+	State         StateConfig      `json:"state"`
+	Notifiers     []NotifierConfig `json:"notifiers"`
+	ListenAddress string           `json:"listen_address"`
+	// LivenessIntervals is the number of check intervals after which the
+	// monitor is considered dead when no check cycle completed.
+	LivenessIntervals int `json:"liveness_intervals"`
+	// Concurrency is the number of repositories that are fetched at the same
+	// time and FetchTimeout limits the duration of a single fetch.
+	Concurrency  int      `json:"concurrency"`
+	FetchTimeout Duration `json:"fetch_timeout"`
+	// Template is the text/template used for the messages of reports by the
+	// notifiers that do not have a template of their own.
+	Template string `json:"template"`
+	// StartTemplate is the text/template of the start message.
+	StartTemplate string `json:"start_template"`
+	// Batch configures sending the reports of a check cycle, or a window of
+	// time, in a single notification.
+	Batch BatchConfig `json:"batch"`
+	// Routes send reports to specific notifiers instead of all of them.
+	Routes []Route `json:"routes"`
 }
 
 func (c Config) String() string {
-	repositories, _ := yaml.Marshal(c.Repositories)
+	// The proxy URL may contain credentials, which should not be printed.
+	printed := append([]Repository(nil), c.Repositories...)
+	for i := range printed {
+		printed[i].Proxy = redactURL(printed[i].Proxy)
+	}
+
+	repositories, _ := yaml.Marshal(printed)
 	return fmt.Sprintf(`Configuration:
 Webhook: %s
 Check interval: %s
@@ -56,6 +258,16 @@ Repositories:
 `, c.WebhookURL, c.CheckInterval, c.ReportStart, "```\n"+string(repositories)+"```")
 }
 
+// redactURL replaces the password in the URL with xxxxx.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+
+	return u.Redacted()
+}
+
 func (c *Config) DependeesForChart(repository string, chart ChartName) []string {
 	for _, c := range c.ChartsForRepository(repository) {
 		if c.Name == chart {
@@ -66,6 +278,18 @@ func (c *Config) DependeesForChart(repository string, chart ChartName) []string
 	return make([]string, 0)
 }
 
+// RepositoryName returns the name of the repository, or its URL when it has
+// no name.
+func (c *Config) RepositoryName(repository string) string {
+	for _, r := range c.Repositories {
+		if r.URL == repository && r.Name != "" {
+			return r.Name
+		}
+	}
+
+	return repository
+}
+
 func (c *Config) ChartsForRepository(repository string) []Chart {
 	for _, r := range c.Repositories {
 		if r.URL == repository {
@@ -78,8 +302,12 @@ func (c *Config) ChartsForRepository(repository string) []Chart {
 
 func DefaultConfig() Config {
 	return Config{
-		CheckInterval: Duration(1 * time.Hour),
-		ReportStart:   true,
+		CheckInterval:     Duration(1 * time.Hour),
+		ReportStart:       true,
+		ListenAddress:     ":8080",
+		LivenessIntervals: 3,
+		Concurrency:       4,
+		FetchTimeout:      Duration(1 * time.Minute),
 	}
 }
 
@@ -107,10 +335,117 @@ func (c Config) FromEnvironment() Config {
 	PopulateStringFromEnvironment(ENV_WebhookURL, &c.WebhookURL)
 	PopulateBooleanFromEnvironment(ENV_ReportStart, &c.ReportStart)
 	PopulateDurationFromEnvironment(ENV_CheckInterval, &c.CheckInterval)
+	PopulateNotifiersFromEnvironment(ENV_Notifiers, &c.Notifiers)
+	PopulateStringFromEnvironment(ENV_ListenAddress, &c.ListenAddress)
+	PopulateIntegerFromEnvironment(ENV_LivenessIntervals, &c.LivenessIntervals)
+	PopulateIntegerFromEnvironment(ENV_Concurrency, &c.Concurrency)
+	PopulateDurationFromEnvironment(ENV_FetchTimeout, &c.FetchTimeout)
+	PopulateStringFromEnvironment(ENV_StateType, &c.State.Type)
+	PopulateStringFromEnvironment(ENV_StatePath, &c.State.Path)
+	PopulateStringFromEnvironment(ENV_Template, &c.Template)
+	PopulateStringFromEnvironment(ENV_StartTemplate, &c.StartTemplate)
+	PopulateStringFromEnvironment(ENV_BatchMode, &c.Batch.Mode)
+	PopulateDurationFromEnvironment(ENV_BatchWindow, &c.Batch.Window)
 	return c
 }
 
 func (c Config) Validate() error {
+	err := c.ValidateRepositories()
+	if err != nil {
+		return err
+	}
+
+	if c.WebhookURL == "" && len(c.Notifiers) == 0 {
+		return errors.New("no webhookURL or notifiers configured")
+	}
+
+	names := make(map[string]bool)
+	for _, n := range c.Notifiers {
+		err := n.Validate()
+		if err != nil {
+			return fmt.Errorf("notifiers contains an invalid notifier: %w", err)
+		}
+
+		if names[n.Name] || (n.Name == DefaultNotifierName && c.WebhookURL != "") {
+			return fmt.Errorf("notifier name %s is used more than once", n.Name)
+		}
+		names[n.Name] = true
+	}
+
+	if c.WebhookURL != "" {
+		names[DefaultNotifierName] = true
+	}
+	for _, r := range c.Routes {
+		err := r.Validate()
+		if err != nil {
+			return fmt.Errorf("routes contains an invalid route: %w", err)
+		}
+
+		for _, name := range r.Notifiers {
+			if !names[name] {
+				return fmt.Errorf("route refers to unknown notifier %s", name)
+			}
+		}
+	}
+
+	err = c.validateFallback()
+	if err != nil {
+		return err
+	}
+
+	err = c.Batch.Validate()
+	if err != nil {
+		return err
+	}
+
+	for _, text := range []string{c.Template, c.StartTemplate} {
+		if text == "" {
+			continue
+		}
+
+		_, err = ParseMessageTemplate(text)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.State.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid state configuration: %w", err)
+	}
+
+	return nil
+}
+
+// validateFallback makes sure reports that match no route still reach a
+// notifier, either through a fallback route or a notifier without routes.
+func (c Config) validateFallback() error {
+	if len(c.Routes) == 0 {
+		return nil
+	}
+
+	routed := make(map[string]bool)
+	for _, r := range c.Routes {
+		if r.Fallback {
+			return nil
+		}
+		for _, name := range r.Notifiers {
+			routed[name] = true
+		}
+	}
+
+	for _, nc := range c.NotifierConfigs() {
+		if !routed[nc.Name] {
+			return nil
+		}
+	}
+
+	return errors.New("every notifier is used by a route, add a fallback route for the reports that match no route")
+}
+
+// ValidateRepositories only validates the repositories, which is all the check
+// command needs.
+func (c Config) ValidateRepositories() error {
 	if c.Repositories == nil {
 		return errors.New("no repositories configured")
 	}
@@ -126,9 +461,101 @@ func (c Config) Validate() error {
 		}
 	}
 
-	if c.WebhookURL == "" {
-		return errors.New("no webhookURL configured")
+	return nil
+}
+
+// LivenessMaxAge returns how long ago the last check cycle may have completed
+// for the monitor to be considered live.
+func (c Config) LivenessMaxAge() time.Duration {
+	intervals := c.LivenessIntervals
+	if intervals < 1 {
+		intervals = 1
+	}
+
+	return time.Duration(intervals) * c.CheckInterval.Duration()
+}
+
+// NotifierConfigs returns the configured notifiers, including a Slack notifier
+// for the webhook_url setting when it is set.
+func (c Config) NotifierConfigs() []NotifierConfig {
+	configs := make([]NotifierConfig, 0, len(c.Notifiers)+1)
+	if c.WebhookURL != "" {
+		configs = append(configs, NotifierConfig{
+			Name: DefaultNotifierName,
+			Type: NotifierTypeSlack,
+			URL:  c.WebhookURL,
+		})
+	}
+	configs = append(configs, c.Notifiers...)
+
+	for i := range configs {
+		if configs[i].Template == "" {
+			configs[i].Template = c.Template
+		}
+	}
+
+	return configs
+}
+
+func (c Config) BuildNotifiers() ([]Notifier, error) {
+	notifiers := make([]Notifier, 0)
+	for _, nc := range c.NotifierConfigs() {
+		n, err := NewNotifier(nc)
+		if err != nil {
+			return nil, err
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	return notifiers, nil
+}
+
+// BuildDeliveryWindows returns the delivery windows of the notifiers that have
+// one, by the name of the notifier.
+func (c Config) BuildDeliveryWindows() (map[string]*DeliveryWindow, error) {
+	windows := make(map[string]*DeliveryWindow)
+	for _, nc := range c.NotifierConfigs() {
+		if nc.DeliveryWindow == "" {
+			continue
+		}
+
+		window, err := ParseDeliveryWindow(nc.DeliveryWindow)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+		windows[nc.Name] = window
+	}
+
+	return windows, nil
+}
+
+// BuildHTTPClients builds the client of every repository once, so the fetches
+// of a repository share its connections.
+func (c *Config) BuildHTTPClients() error {
+	for i, r := range c.Repositories {
+		client, err := r.HTTPClient()
+		if err != nil {
+			return fmt.Errorf("repository %s: %w", r.URL, err)
+		}
+		c.Repositories[i].client = client
 	}
 
 	return nil
 }
+
+// BuildRouter returns the router for the routes, or nil when there are none so
+// every report goes to every notifier.
+func (c Config) BuildRouter() (*Router, error) {
+	if len(c.Routes) == 0 {
+		return nil, nil
+	}
+
+	configs := c.NotifierConfigs()
+	names := make([]string, len(configs))
+	for i, nc := range configs {
+		names[i] = nc.Name
+	}
+
+	return NewRouter(c.Routes, names)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// digestsBucket holds the reports that are collected for digests, keyed by
+// digest key.
+const digestsBucket = "digests"
+
+// Enqueuer accepts notifications for delivery.
+type Enqueuer interface {
+	Enqueue(notification Notification) error
+}
+
+// PendingDigest contains the reports that are collected for a digest and the
+// moment they are due.
+type PendingDigest struct {
+	Reports []Report  `json:"reports"`
+	Due     time.Time `json:"due"`
+}
+
+func chartDigestKey(repository string, chart ChartName) string {
+	return "chart:" + repository + "#" + string(chart)
+}
+
+func notifierDigestKey(notifier string) string {
+	return "notifier:" + notifier
+}
+
+// Digests collects reports and delivers them as a single notification at the
+// moment of their schedule. Charts as well as notifiers can have a digest
+// schedule. The reports are kept in the store, so they survive restarts.
+type Digests struct {
+	store     Store
+	schedules map[string]*Schedule
+	// deliver is called with the key and the reports of a digest when it is
+	// due.
+	deliver func(key string, notification Notification) error
+	now     func() time.Time
+	wake    chan struct{}
+	mutex   sync.Mutex
+}
+
+// NewDigests creates the digests for the charts and notifiers that have a
+// digest schedule and hands the notifier digests to the dispatcher. Due chart
+// digests are dispatched, due notifier digests are put in the outbox for the
+// notifier.
+func NewDigests(config Config, store Store, outbox *Outbox, dispatcher *Dispatcher) (*Digests, error) {
+	d := &Digests{
+		store:     store,
+		schedules: make(map[string]*Schedule),
+		now:       time.Now,
+		wake:      make(chan struct{}, 1),
+	}
+
+	for _, repo := range config.Repositories {
+		for _, chart := range repo.Charts {
+			if chart.Digest == "" {
+				continue
+			}
+
+			schedule, err := ParseSchedule(chart.Digest)
+			if err != nil {
+				return nil, err
+			}
+			d.schedules[chartDigestKey(repo.URL, chart.Name)] = schedule
+		}
+	}
+
+	notifierKeys := make(map[string]string)
+	for _, nc := range config.NotifierConfigs() {
+		if nc.Digest == "" {
+			continue
+		}
+
+		schedule, err := ParseSchedule(nc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		d.schedules[notifierDigestKey(nc.Name)] = schedule
+		notifierKeys[notifierDigestKey(nc.Name)] = nc.Name
+	}
+
+	d.deliver = func(key string, notification Notification) error {
+		if name, ok := notifierKeys[key]; ok {
+			return outbox.EnqueueFor(name, notification)
+		}
+
+		return dispatcher.Enqueue(notification)
+	}
+	dispatcher.digests = d
+
+	return d, nil
+}
+
+// Has returns whether there is a digest schedule for the key.
+func (d *Digests) Has(key string) bool {
+	return d != nil && d.schedules[key] != nil
+}
+
+// Add adds the report to the digest with the key.
+func (d *Digests) Add(key string, report Report) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var digest PendingDigest
+	found, err := d.store.Get(digestsBucket, key, &digest)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		digest.Due = d.schedules[key].Next(d.now())
+	}
+	digest.Reports = append(digest.Reports, report)
+
+	err = d.store.Put(digestsBucket, key, digest)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run delivers the digests when they are due until the context is cancelled.
+func (d *Digests) Run(ctx context.Context) {
+	for {
+		wait := d.DeliverDue()
+		if wait <= 0 {
+			wait = time.Hour
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-d.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// DeliverDue delivers the digests that are due and returns the time until the
+// next one is due, or 0 when there are none. Digests without a schedule, for
+// instance because it was removed from the configuration, are due right away.
+// A digest stays in the store until it is delivered, so a restart in between
+// does not lose its reports.
+func (d *Digests) DeliverDue() time.Duration {
+	due, next := d.findDue()
+
+	// The lock is not held whilst delivering, as delivering a chart digest can
+	// add its reports to the digest of a notifier.
+	for key, digest := range due {
+		err := d.deliver(key, Notification{Reports: digest.Reports})
+		if err == nil {
+			d.remove(key, len(digest.Reports))
+			continue
+		}
+
+		log.Println("Could not deliver digest", key, err)
+		retry := d.now().Add(time.Minute)
+		d.postpone(key, retry)
+		if next.IsZero() || retry.Before(next) {
+			next = retry
+		}
+	}
+
+	if next.IsZero() {
+		return 0
+	}
+
+	return next.Sub(d.now())
+}
+
+// findDue returns the digests that are due, together with the moment the next
+// digest is due.
+func (d *Digests) findDue() (map[string]PendingDigest, time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	due := make(map[string]PendingDigest)
+	var next time.Time
+
+	keys, err := d.store.Keys(digestsBucket)
+	if err != nil {
+		log.Println("Could not list the digests", err)
+		return due, d.now().Add(time.Minute)
+	}
+
+	now := d.now()
+	for _, key := range keys {
+		var digest PendingDigest
+		found, err := d.store.Get(digestsBucket, key, &digest)
+		if err != nil || !found {
+			continue
+		}
+
+		if digest.Due.After(now) && d.schedules[key] != nil {
+			if next.IsZero() || digest.Due.Before(next) {
+				next = digest.Due
+			}
+			continue
+		}
+
+		due[key] = digest
+	}
+
+	return due, next
+}
+
+// remove removes the delivered reports from the digest. Reports that were
+// added whilst it was delivered stay behind for the next moment of the
+// schedule.
+func (d *Digests) remove(key string, delivered int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var digest PendingDigest
+	_, err := d.store.Get(digestsBucket, key, &digest)
+	if err != nil {
+		log.Println("Could not load digest", key, err)
+		return
+	}
+
+	if len(digest.Reports) <= delivered {
+		err = d.store.Delete(digestsBucket, key)
+		if err != nil {
+			log.Println("Could not remove digest", key, err)
+		}
+		return
+	}
+
+	digest.Reports = digest.Reports[delivered:]
+	digest.Due = d.now()
+	if schedule := d.schedules[key]; schedule != nil {
+		digest.Due = schedule.Next(digest.Due)
+	}
+
+	err = d.store.Put(digestsBucket, key, digest)
+	if err != nil {
+		log.Println("Could not save digest", key, err)
+	}
+}
+
+// postpone moves a digest that could not be delivered to the retry.
+func (d *Digests) postpone(key string, retry time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var digest PendingDigest
+	found, err := d.store.Get(digestsBucket, key, &digest)
+	if err != nil || !found {
+		log.Println("Could not load digest", key, err)
+		return
+	}
+
+	digest.Due = retry
+	err = d.store.Put(digestsBucket, key, digest)
+	if err != nil {
+		log.Println("Could not save digest", key, err)
+	}
+}
+
+// Dispatcher puts notifications in the outbox for every notifier, except for
+// notifiers with a digest schedule, which get the reports in their digest.
+// Each notifier only gets the reports the router sends to it.
+type Dispatcher struct {
+	outbox    *Outbox
+	digests   *Digests
+	router    *Router
+	notifiers []string
+}
+
+func NewDispatcher(outbox *Outbox, notifiers []Notifier, router *Router) *Dispatcher {
+	names := make([]string, len(notifiers))
+	for i, n := range notifiers {
+		names[i] = n.Name()
+	}
+
+	return &Dispatcher{outbox: outbox, router: router, notifiers: names}
+}
+
+func (d *Dispatcher) Enqueue(notification Notification) error {
+	d.observeUnrouted(notification)
+	for _, name := range d.notifiers {
+		routed := d.route(notification, name)
+		if routed.Text == "" && len(routed.Reports) == 0 {
+			continue
+		}
+
+		key := notifierDigestKey(name)
+		if routed.Text != "" || !d.digests.Has(key) {
+			err := d.outbox.EnqueueFor(name, routed)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, r := range routed.Reports {
+			err := d.digests.Add(key, r)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// route returns the notification with only the reports for the notifier.
+// Notifications with a text are meant for every notifier.
+func (d *Dispatcher) route(notification Notification, name string) Notification {
+	if notification.Text != "" {
+		return notification
+	}
+
+	reports := make([]Report, 0, len(notification.Reports))
+	for _, r := range notification.Reports {
+		if d.router.Routes(r, name) {
+			reports = append(reports, r)
+		}
+	}
+
+	return Notification{Reports: reports}
+}
+
+// observeUnrouted logs and counts the reports no notifier is routed to, which
+// are not delivered.
+func (d *Dispatcher) observeUnrouted(notification Notification) {
+	if notification.Text != "" {
+		return
+	}
+
+	for _, r := range notification.Reports {
+		routed := false
+		for _, name := range d.notifiers {
+			if d.router.Routes(r, name) {
+				routed = true
+				break
+			}
+		}
+
+		if !routed {
+			log.Println("No notifier is routed to", r.Chart, "from", r.Repository, "so it is not delivered")
+			metrics.ObserveUnroutedReport(r.Repository)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testDigests(t *testing.T, config Config, notifiers ...Notifier) (*Digests, *Dispatcher, *Outbox, *time.Time) {
+	store := NewMemoryStore()
+	outbox, now := testOutbox(store, notifiers...)
+	dispatcher := NewDispatcher(outbox, notifiers, nil)
+	digests, err := NewDigests(config, store, outbox, dispatcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests.now = outbox.now
+
+	return digests, dispatcher, outbox, now
+}
+
+func TestDigests_Chart(t *testing.T) {
+	config := Config{Repositories: []Repository{{
+		URL:    "https://example.com/index.yaml",
+		Charts: []Chart{{Name: "chart", Digest: "every monday 09:00 UTC"}, {Name: "other"}},
+	}}}
+	first := &fakeNotifier{name: "first"}
+	second := &fakeNotifier{name: "second"}
+	digests, _, outbox, now := testDigests(t, config, first, second)
+
+	key := chartDigestKey("https://example.com/index.yaml", "chart")
+	Equals(digests.Has(key), true, t)
+	Equals(digests.Has(chartDigestKey("https://example.com/index.yaml", "other")), false, t)
+
+	// Sunday 1 January 2023 00:00, so the digest is due in 33 hours.
+	_ = digests.Add(key, testReport())
+	_ = digests.Add(key, testReport())
+	Equals(digests.DeliverDue(), 33*time.Hour, t)
+	Equals(outbox.Pending(), 0, t)
+
+	*now = now.Add(33 * time.Hour)
+	Equals(digests.DeliverDue(), time.Duration(0), t)
+	outbox.Deliver()
+
+	Equals(len(first.notifications), 1, t)
+	Equals(len(first.notifications[0].Reports), 2, t)
+	Equals(len(second.notifications), 1, t)
+}
+
+func TestDigests_Notifier(t *testing.T) {
+	config := Config{Notifiers: []NotifierConfig{
+		{Name: "realtime", Type: NotifierTypeStdout},
+		{Name: "weekly", Type: NotifierTypeStdout, Digest: "every monday 09:00 UTC"},
+	}}
+	realtime := &fakeNotifier{name: "realtime"}
+	weekly := &fakeNotifier{name: "weekly"}
+	digests, dispatcher, outbox, now := testDigests(t, config, realtime, weekly)
+
+	_ = dispatcher.Enqueue(Notification{Reports: []Report{testReport()}})
+	_ = dispatcher.Enqueue(Notification{Text: "started"})
+	outbox.Deliver()
+	Equals(len(realtime.notifications), 2, t)
+	Equals(len(weekly.notifications), 1, t)
+	Equals(weekly.notifications[0].Text, "started", t)
+
+	*now = now.Add(33 * time.Hour)
+	digests.DeliverDue()
+	outbox.Deliver()
+
+	Equals(len(realtime.notifications), 2, t)
+	Equals(len(weekly.notifications), 2, t)
+	Equals(len(weekly.notifications[1].Reports), 1, t)
+}
+
+func TestDigests_ChartAndNotifier(t *testing.T) {
+	config := Config{
+		Repositories: []Repository{{URL: "repo", Charts: []Chart{{Name: "chart", Digest: "every day 09:00 UTC"}}}},
+		Notifiers:    []NotifierConfig{{Name: "weekly", Type: NotifierTypeStdout, Digest: "every monday 09:00 UTC"}},
+	}
+	weekly := &fakeNotifier{name: "weekly"}
+	digests, _, outbox, now := testDigests(t, config, weekly)
+
+	_ = digests.Add(chartDigestKey("repo", "chart"), testReport())
+	*now = now.Add(9 * time.Hour)
+	digests.DeliverDue()
+	outbox.Deliver()
+	Equals(len(weekly.notifications), 0, t)
+
+	*now = now.Add(24 * time.Hour)
+	digests.DeliverDue()
+	outbox.Deliver()
+	Equals(len(weekly.notifications), 1, t)
+}
+
+func TestDigests_UnknownScheduleIsDue(t *testing.T) {
+	notifier := &fakeNotifier{name: "team"}
+	digests, _, outbox, _ := testDigests(t, Config{}, notifier)
+	_ = digests.store.Put(digestsBucket, chartDigestKey("repo", "removed"), PendingDigest{
+		Reports: []Report{testReport()},
+		Due:     time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	Equals(digests.DeliverDue(), time.Duration(0), t)
+	outbox.Deliver()
+	Equals(len(notifier.notifications), 1, t)
+}
+
+func TestDigests_KeptUntilDelivered(t *testing.T) {
+	config := Config{Repositories: []Repository{{URL: "repo", Charts: []Chart{{Name: "chart", Digest: "every day 09:00 UTC"}}}}}
+	digests, _, _, now := testDigests(t, config, &fakeNotifier{name: "team"})
+	key := chartDigestKey("repo", "chart")
+	_ = digests.Add(key, testReport())
+	*now = now.Add(9 * time.Hour)
+
+	stored := false
+	digests.deliver = func(key string, notification Notification) error {
+		stored, _ = digests.store.Get(digestsBucket, key, &PendingDigest{})
+		_ = digests.Add(key, testReport())
+		return errors.New("outbox unavailable")
+	}
+	Equals(digests.DeliverDue(), time.Minute, t)
+	Equals(stored, true, t)
+
+	var delivered []Report
+	digests.deliver = func(key string, notification Notification) error {
+		delivered = notification.Reports
+		return digests.Add(key, testReport())
+	}
+	*now = now.Add(time.Minute)
+	digests.DeliverDue()
+	Equals(len(delivered), 2, t)
+
+	// The report that was added during the delivery waits for the next day.
+	var remaining PendingDigest
+	_, _ = digests.store.Get(digestsBucket, key, &remaining)
+	Equals(len(remaining.Reports), 1, t)
+	Equals(remaining.Due, time.Date(2023, 1, 2, 9, 0, 0, 0, time.UTC), t)
+}
+
+func TestDispatcher_Enqueue_Routes(t *testing.T) {
+	database := &fakeNotifier{name: "database"}
+	platform := &fakeNotifier{name: "platform"}
+	store := NewMemoryStore()
+	outbox, now := testOutbox(store, database, platform)
+	router, _ := NewRouter([]Route{{Chart: "postgres*", Notifiers: []string{"database"}}}, []string{"database", "platform"})
+	dispatcher := NewDispatcher(outbox, []Notifier{database, platform}, router)
+	_, _ = NewDigests(Config{}, store, outbox, dispatcher)
+
+	postgres := testReport()
+	postgres.Chart = "postgresql"
+	_ = dispatcher.Enqueue(Notification{Reports: []Report{postgres, testReport()}})
+	*now = now.Add(time.Second)
+	_ = dispatcher.Enqueue(Notification{Text: "started"})
+	outbox.Deliver()
+
+	Equals(len(database.notifications), 2, t)
+	Equals(len(database.notifications[0].Reports), 1, t)
+	Equals(database.notifications[0].Reports[0].Chart, ChartName("postgresql"), t)
+	Equals(len(platform.notifications), 2, t)
+	Equals(platform.notifications[0].Reports[0].Chart, ChartName("chart"), t)
+}
+
+func TestDispatcher_Enqueue_CountsUnroutedReports(t *testing.T) {
+	database := &fakeNotifier{name: "database"}
+	store := NewMemoryStore()
+	outbox, _ := testOutbox(store, database)
+	router, _ := NewRouter([]Route{{Chart: "postgres*", Notifiers: []string{"database"}}}, []string{"database"})
+	dispatcher := NewDispatcher(outbox, []Notifier{database}, router)
+	_, _ = NewDigests(Config{}, store, outbox, dispatcher)
+	metrics = NewMetrics()
+
+	report := testReport()
+	report.Repository = "https://unrouted.example.com"
+	_ = dispatcher.Enqueue(Notification{Reports: []Report{report}})
+
+	Equals(outbox.Pending(), 0, t)
+	Equals(metrics.unroutedReports["https://unrouted.example.com"], float64(1), t)
+}
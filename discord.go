@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxDiscordEmbeds is the number of embeds Discord accepts in a message,
+	// more reports are spread over several messages.
+	maxDiscordEmbeds = 10
+	// maxDiscordContent is the maximum length of the content of a message,
+	// maxDiscordTitle of the title of an embed and maxDiscordField of the value
+	// of a field, all in characters.
+	maxDiscordContent = 2000
+	maxDiscordTitle   = 256
+	maxDiscordField   = 1024
+	// maxDiscordAttempts limits how often a rate limited message is retried.
+	maxDiscordAttempts = 3
+	// maxDiscordWait is the longest the notifier waits for the rate limit
+	// itself, longer waits are left to the outbox.
+	maxDiscordWait = 5 * time.Second
+)
+
+// discordColors are the colours of the embed of a report.
+var discordColors = map[Bump]int{
+	BumpMajor: 0xe01e5a,
+	BumpMinor: 0xecb22e,
+	BumpPatch: 0x2eb67d,
+	BumpNone:  0x868686,
+}
+
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+}
+
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	URL         string              `json:"url,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+}
+
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// DiscordNotifier posts notifications as embeds to a Discord webhook. It keeps
+// track of the rate limit headers of Discord, so it waits instead of being
+// rejected when the bucket of the webhook is exhausted.
+type DiscordNotifier struct {
+	name     string
+	url      string
+	client   *http.Client
+	template *MessageTemplate
+
+	mutex sync.Mutex
+	// resetAt is set when the rate limit is exhausted and holds the time at
+	// which Discord accepts messages again.
+	resetAt time.Time
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+func NewDiscordNotifier(name, url string) *DiscordNotifier {
+	return &DiscordNotifier{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: notifierTimeout},
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+func (d *DiscordNotifier) Name() string {
+	return d.name
+}
+
+// MaxReports makes the outbox send every message of embeds as a notification
+// of its own, so the messages that were sent are not sent again on a retry.
+func (d *DiscordNotifier) MaxReports() int {
+	return maxDiscordEmbeds
+}
+
+func (d *DiscordNotifier) Notify(notification Notification) error {
+	messages, err := d.messages(notification)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, m := range messages {
+		err = d.send(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *DiscordNotifier) messages(notification Notification) ([]DiscordMessage, error) {
+	if d.template != nil || notification.Text != "" {
+		text, err := d.template.Render(notification)
+		if err != nil {
+			return nil, err
+		}
+
+		return []DiscordMessage{{Content: truncate(text, maxDiscordContent)}}, nil
+	}
+
+	messages := make([]DiscordMessage, 0)
+	for i, r := range notification.Reports {
+		if i%maxDiscordEmbeds == 0 {
+			messages = append(messages, DiscordMessage{})
+		}
+
+		last := &messages[len(messages)-1]
+		last.Embeds = append(last.Embeds, discordEmbed(r))
+	}
+
+	return messages, nil
+}
+
+func discordEmbed(r Report) DiscordEmbed {
+	bump := r.Bump()
+	title := fmt.Sprintf("%s updated to %s", r.Chart, r.NewVersion)
+	if r.Preview {
+		title = fmt.Sprintf("%s has a new preview version %s", r.Chart, r.NewVersion)
+	}
+
+	repository := r.RepositoryName
+	if repository == "" {
+		repository = r.Repository
+	}
+
+	fields := []DiscordEmbedField{
+		{Name: "Previous version", Value: r.PreviousVersion.String(), Inline: true},
+		{Name: "New version", Value: r.NewVersion.String(), Inline: true},
+		{Name: "Bump", Value: bump.String(), Inline: true},
+		{Name: "Repository", Value: truncate(repository, maxDiscordField)},
+	}
+	if len(r.NewVersions) > 1 {
+		fields = append(fields, DiscordEmbedField{Name: "Released", Value: truncate(strings.Join(versionStrings(r.NewVersions), ", "), maxDiscordField)})
+	}
+	if len(r.Dependees) > 0 {
+		fields = append(fields, DiscordEmbedField{Name: "Dependees", Value: truncate(strings.Join(r.Dependees, ", "), maxDiscordField)})
+	}
+
+	return DiscordEmbed{
+		Title:       truncate(title, maxDiscordTitle),
+		Description: r.Metadata.Description,
+		URL:         r.Metadata.Home,
+		Color:       discordColors[bump],
+		Fields:      fields,
+	}
+}
+
+// truncate shortens text to at most max characters.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+
+	return string(runes[:max-3]) + "..."
+}
+
+// send posts a message, waiting for the rate limit to reset when it is
+// exhausted and retrying when Discord answers with 429 Too Many Requests.
+// Waits longer than maxDiscordWait are returned as a RetryAfterError instead.
+func (d *DiscordNotifier) send(message DiscordMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if wait := d.resetAt.Sub(d.now()); wait > maxDiscordWait {
+			return &RetryAfterError{After: wait, Err: errors.New("discord rate limit exhausted")}
+		} else if wait > 0 {
+			d.sleep(wait)
+		}
+
+		response, err := d.client.Post(d.url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		response.Body.Close()
+		d.updateRateLimit(response.Header)
+
+		if response.StatusCode == http.StatusTooManyRequests {
+			retryAfter := discordRetryAfter(response.Header, body)
+			if attempt < maxDiscordAttempts {
+				d.resetAt = d.now().Add(retryAfter)
+				continue
+			}
+
+			return &RetryAfterError{After: retryAfter, Err: fmt.Errorf("unexpected response code %d", response.StatusCode)}
+		}
+
+		if response.StatusCode >= 300 {
+			return statusError(response.StatusCode, fmt.Errorf("unexpected response code %d", response.StatusCode))
+		}
+
+		return nil
+	}
+}
+
+// updateRateLimit remembers when the bucket resets when no requests remain.
+func (d *DiscordNotifier) updateRateLimit(header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err == nil {
+		d.resetAt = d.now().Add(time.Duration(resetAfter * float64(time.Second)))
+	}
+}
+
+// discordRetryAfter returns how long to wait after a 429. Discord puts it in
+// the body as well as in the Retry-After header, both in seconds.
+func discordRetryAfter(header http.Header, body []byte) time.Duration {
+	var limited struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &limited) == nil && limited.RetryAfter > 0 {
+		return time.Duration(limited.RetryAfter * float64(time.Second))
+	}
+
+	retryAfter, err := strconv.ParseFloat(header.Get("Retry-After"), 64)
+	if err == nil && retryAfter > 0 {
+		return time.Duration(retryAfter * float64(time.Second))
+	}
+
+	return time.Second
+}
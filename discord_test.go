@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestDiscordNotifier_Notify(t *testing.T) {
+	var received DiscordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	report := testReport()
+	report.Metadata = Entry{Home: "https://example.com/chart", Description: "An example"}
+	err := NewDiscordNotifier("community", server.URL).Notify(Notification{Reports: []Report{report}})
+
+	Equals(err, nil, t)
+	Equals(received.Content, "", t)
+	Equals(len(received.Embeds), 1, t)
+
+	embed := received.Embeds[0]
+	Equals(embed.Title, "chart updated to 1.1.0", t)
+	Equals(embed.URL, "https://example.com/chart", t)
+	Equals(embed.Description, "An example", t)
+	Equals(embed.Color, discordColors[BumpMinor], t)
+	MapsEqual(embed.Fields, []DiscordEmbedField{
+		{Name: "Previous version", Value: "1.0.0", Inline: true},
+		{Name: "New version", Value: "1.1.0", Inline: true},
+		{Name: "Bump", Value: "minor", Inline: true},
+		{Name: "Repository", Value: "https://example.com/index.yaml"},
+		{Name: "Released", Value: "1.0.1, 1.1.0"},
+		{Name: "Dependees", Value: "Example"},
+	}, t)
+}
+
+func TestDiscordNotifier_Notify_Text(t *testing.T) {
+	var received DiscordMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewDiscordNotifier("community", server.URL).Notify(Notification{Text: "started"})
+
+	Equals(err, nil, t)
+	Equals(received.Content, "started", t)
+	Equals(len(received.Embeds), 0, t)
+}
+
+func TestDiscordNotifier_Notify_SplitsEmbeds(t *testing.T) {
+	messages := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		messages++
+	}))
+	defer server.Close()
+
+	reports := make([]Report, maxDiscordEmbeds+1)
+	for i := range reports {
+		reports[i] = testReport()
+	}
+	err := NewDiscordNotifier("community", server.URL).Notify(Notification{Reports: reports})
+
+	Equals(err, nil, t)
+	Equals(messages, 2, t)
+}
+
+func TestDiscordNotifier_Notify_RateLimited(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.25, "global": false}`))
+			return
+		}
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "2.5")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	slept := make([]time.Duration, 0)
+	notifier := NewDiscordNotifier("community", server.URL)
+	notifier.now = func() time.Time { return now }
+	notifier.sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	err := notifier.Notify(Notification{Text: "first"})
+	Equals(err, nil, t)
+	Equals(requests, 2, t)
+	MapsEqual(slept, []time.Duration{250 * time.Millisecond}, t)
+
+	err = notifier.Notify(Notification{Text: "second"})
+	Equals(err, nil, t)
+	MapsEqual(slept, []time.Duration{250 * time.Millisecond, 2500 * time.Millisecond}, t)
+}
+
+func TestDiscordNotifier_Notify_GivesUp(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	notifier := NewDiscordNotifier("community", server.URL)
+	notifier.sleep = func(time.Duration) {}
+	err := notifier.Notify(Notification{Text: "started"})
+
+	var retryAfter *RetryAfterError
+	Equals(errors.As(err, &retryAfter), true, t)
+	Equals(retryAfter.After, time.Second, t)
+	Equals(requests, maxDiscordAttempts, t)
+}
+
+func TestDiscordNotifier_Notify_LongWaitIsLeftToOutbox(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"retry_after": 3600}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	notifier := NewDiscordNotifier("community", server.URL)
+	notifier.now = func() time.Time { return now }
+	notifier.sleep = func(d time.Duration) { t.Fatalf("slept for %s", d) }
+	err := notifier.Notify(Notification{Text: "started"})
+
+	var retryAfter *RetryAfterError
+	Equals(errors.As(err, &retryAfter), true, t)
+	Equals(retryAfter.After, time.Hour, t)
+	Equals(requests, 1, t)
+}
+
+func TestDiscordNotifier_Messages_Truncates(t *testing.T) {
+	notifier := NewDiscordNotifier("community", "")
+	messages, _ := notifier.messages(Notification{Text: strings.Repeat("é", maxDiscordContent+1)})
+
+	Equals(utf8.ValidString(messages[0].Content), true, t)
+	Equals(utf8.RuneCountInString(messages[0].Content), maxDiscordContent, t)
+
+	report := testReport()
+	report.Dependees = make([]string, 500)
+	for i := range report.Dependees {
+		report.Dependees[i] = "dependee"
+	}
+	fields := discordEmbed(report).Fields
+	Equals(utf8.RuneCountInString(fields[len(fields)-1].Value), maxDiscordField, t)
+}
+
+func TestDiscordRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+
+	Equals(discordRetryAfter(header, []byte(`{"retry_after": 1.5}`)), 1500*time.Millisecond, t)
+	Equals(discordRetryAfter(header, nil), 3*time.Second, t)
+	Equals(discordRetryAfter(http.Header{}, nil), time.Second, t)
+}
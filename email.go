@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EmailSecurityStartTLS = "starttls"
+	EmailSecurityNone     = "none"
+)
+
+// EmailConfig configures the SMTP server and the addresses of the email
+// notifier.
+type EmailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password *Secret  `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// Security is either starttls, the default, which refuses to send mail
+	// when the server does not support STARTTLS, or none.
+	Security string `json:"security,omitempty"`
+}
+
+func (e *EmailConfig) Validate() error {
+	if e == nil {
+		return errors.New("email settings are missing")
+	}
+
+	if e.Host == "" {
+		return errors.New("email requires a host")
+	}
+
+	if e.From == "" || len(e.To) == 0 {
+		return errors.New("email requires a from address and at least one to address")
+	}
+
+	switch e.Security {
+	case "", EmailSecurityStartTLS, EmailSecurityNone:
+	default:
+		return fmt.Errorf("unknown email security %s", e.Security)
+	}
+
+	if e.Password.IsSet() && e.Username == "" {
+		return errors.New("email contains a password without a username")
+	}
+
+	return e.Password.Validate()
+}
+
+func (e *EmailConfig) address() string {
+	port := e.Port
+	if port == 0 {
+		port = 587
+	}
+
+	return net.JoinHostPort(e.Host, strconv.Itoa(port))
+}
+
+// EmailNotifier sends notifications as mails with a plain text and an HTML
+// part.
+type EmailNotifier struct {
+	name     string
+	config   EmailConfig
+	template *MessageTemplate
+}
+
+func NewEmailNotifier(name string, config EmailConfig) *EmailNotifier {
+	return &EmailNotifier{name: name, config: config}
+}
+
+func (e *EmailNotifier) Name() string {
+	return e.name
+}
+
+func (e *EmailNotifier) Notify(notification Notification) error {
+	message, err := e.message(notification, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", e.config.address(), notifierTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(notifierTimeout))
+
+	client, err := smtp.NewClient(conn, e.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.config.Security != EmailSecurityNone {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS", e.config.Host)
+		}
+
+		err = client.StartTLS(&tls.Config{ServerName: e.config.Host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.config.Username != "" {
+		password, err := e.config.Password.Resolve()
+		if err != nil {
+			return err
+		}
+
+		err = client.Auth(smtp.PlainAuth("", e.config.Username, password, e.config.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(e.config.From)
+	if err != nil {
+		return err
+	}
+
+	for _, to := range e.config.To {
+		err = client.Rcpt(to)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(message)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// message builds a multipart/alternative mail for the notification.
+func (e *EmailNotifier) message(notification Notification, date time.Time) ([]byte, error) {
+	text, err := e.template.Render(notification)
+	if err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	err = emailHTML.Execute(&html, notification)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = w.Write([]byte(strings.ReplaceAll(part.content, "\n", "\r\n")))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = parts.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	headers := [][2]string{
+		{"From", e.config.From},
+		{"To", strings.Join(e.config.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", emailSubject(notification))},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", h[0], h[1])
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func emailSubject(notification Notification) string {
+	switch {
+	case notification.Text != "":
+		return "Chart version monitor"
+	case len(notification.Reports) == 1:
+		r := notification.Reports[0]
+		return fmt.Sprintf("%s updated to %s", r.Chart, r.NewVersion)
+	default:
+		return fmt.Sprintf("%d chart updates", len(notification.Reports))
+	}
+}
+
+var emailHTML = template.Must(template.New("email").Funcs(template.FuncMap{
+	"join":     strings.Join,
+	"versions": versionStrings,
+}).Parse(`<!DOCTYPE html>
+<html>
+<body>
+{{- if .Text }}
+<pre>{{ .Text }}</pre>
+{{- else }}
+<table cellpadding="4" style="border-collapse: collapse">
+<tr><th align="left">Chart</th><th align="left">Repository</th><th align="left">Version</th><th align="left">Bump</th><th align="left">Dependees</th></tr>
+{{- range .Reports }}
+<tr>
+<td>{{ if .Metadata.Home }}<a href="{{ .Metadata.Home }}">{{ .Chart }}</a>{{ else }}{{ .Chart }}{{ end }}{{ if .Preview }} (preview){{ end }}</td>
+<td>{{ if .RepositoryName }}{{ .RepositoryName }}{{ else }}{{ .Repository }}{{ end }}</td>
+<td>{{ .PreviousVersion }} &rarr; <b>{{ .NewVersion }}</b>{{ if gt (len .NewVersions) 1 }}<br><small>{{ join (versions .NewVersions) ", " }}</small>{{ end }}</td>
+<td>{{ .Bump }}</td>
+<td>{{ join .Dependees ", " }}</td>
+</tr>
+{{- end }}
+</table>
+{{- end }}
+</body>
+</html>
+`))
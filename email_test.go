@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is an in-process SMTP server that accepts every mail and records
+// what it received.
+type fakeSMTP struct {
+	listener net.Listener
+	startTLS bool
+
+	mutex      sync.Mutex
+	auth       string
+	recipients []string
+	mails      []string
+}
+
+func newFakeSMTP(t *testing.T, startTLS bool) *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeSMTP{listener: listener, startTLS: startTLS}
+	go f.serve()
+	return f
+}
+
+func (f *fakeSMTP) config() EmailConfig {
+	host, port, _ := net.SplitHostPort(f.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return EmailConfig{
+		Host:     host,
+		Port:     p,
+		From:     "monitor@example.com",
+		To:       []string{"platform@example.com", "security@example.com"},
+		Security: EmailSecurityNone,
+	}
+}
+
+func (f *fakeSMTP) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeSMTP) Mails() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string(nil), f.mails...)
+}
+
+func (f *fakeSMTP) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = io.WriteString(conn, s+"\r\n") }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.TrimSpace(line)
+		verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			if f.startTLS {
+				reply("250-fake")
+				reply("250-STARTTLS")
+			} else {
+				reply("250-fake")
+			}
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			f.mutex.Lock()
+			f.auth = strings.TrimPrefix(command, "AUTH PLAIN ")
+			f.mutex.Unlock()
+			reply("235 Authenticated")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			f.mutex.Lock()
+			f.recipients = append(f.recipients, command)
+			f.mutex.Unlock()
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			f.mutex.Lock()
+			f.mails = append(f.mails, data.String())
+			f.mutex.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+// parseMail returns the subject and the plain text and HTML parts of a mail.
+func parseMail(t *testing.T, raw string) (string, string, string) {
+	message, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	_, params, _ := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	parts := multipart.NewReader(message.Body, params["boundary"])
+
+	contents := make([]string, 0)
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(part)
+		contents = append(contents, string(data))
+	}
+
+	if len(contents) != 2 {
+		t.Fatalf("expected 2 parts, got %d", len(contents))
+	}
+
+	return subject, contents[0], contents[1]
+}
+
+func TestEmailConfig_Validate(t *testing.T) {
+	valid := EmailConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}
+	Equals(valid.Validate(), nil, t)
+
+	var missing *EmailConfig
+	ErrorsEqual(missing.Validate(), errors.New("email settings are missing"), t)
+
+	noHost := valid
+	noHost.Host = ""
+	ErrorsEqual(noHost.Validate(), errors.New("email requires a host"), t)
+
+	noRecipients := valid
+	noRecipients.To = nil
+	ErrorsEqual(noRecipients.Validate(), errors.New("email requires a from address and at least one to address"), t)
+
+	security := valid
+	security.Security = "ssl"
+	ErrorsEqual(security.Validate(), errors.New("unknown email security ssl"), t)
+
+	password := valid
+	password.Password = &Secret{Value: "secret"}
+	ErrorsEqual(password.Validate(), errors.New("email contains a password without a username"), t)
+}
+
+func TestEmailNotifier_Notify(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	defer server.Close()
+
+	config := server.config()
+	config.Username = "monitor"
+	config.Password = &Secret{Value: "secret"}
+	report := testReport()
+	report.Metadata = Entry{Home: "https://example.com/chart"}
+	err := NewEmailNotifier("mail", config).Notify(Notification{Reports: []Report{report}})
+
+	Equals(err, nil, t)
+	Equals(server.auth, base64.StdEncoding.EncodeToString([]byte("\x00monitor\x00secret")), t)
+	Equals(len(server.recipients), 2, t)
+
+	mails := server.Mails()
+	Equals(len(mails), 1, t)
+	subject, text, html := parseMail(t, mails[0])
+	Equals(subject, "chart updated to 1.1.0", t)
+	Equals(text, strings.ReplaceAll(report.Message(), "\n", "\r\n"), t)
+	Equals(strings.Contains(html, `<a href="https://example.com/chart">chart</a>`), true, t)
+	Equals(strings.Contains(html, "1.0.0 &rarr; <b>1.1.0</b>"), true, t)
+}
+
+func TestEmailNotifier_Notify_RequiresStartTLS(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	defer server.Close()
+
+	config := server.config()
+	config.Security = EmailSecurityStartTLS
+	err := NewEmailNotifier("mail", config).Notify(Notification{Text: "started"})
+
+	Equals(err.Error(), "127.0.0.1 does not support STARTTLS", t)
+	Equals(len(server.Mails()), 0, t)
+}
+
+func TestEmailNotifier_Notify_Reports(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	defer server.Close()
+
+	err := NewEmailNotifier("mail", server.config()).Notify(Notification{Reports: []Report{testReport(), testReport()}})
+
+	Equals(err, nil, t)
+	subject, _, html := parseMail(t, server.Mails()[0])
+	Equals(subject, "2 chart updates", t)
+	Equals(strings.Count(html, "<tr>"), 3, t)
+}
+
+func TestEmailNotifier_Notify_TextIsEscaped(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	defer server.Close()
+
+	err := NewEmailNotifier("mail", server.config()).Notify(Notification{Text: "started <now>"})
+
+	Equals(err, nil, t)
+	subject, text, html := parseMail(t, server.Mails()[0])
+	Equals(subject, "Chart version monitor", t)
+	Equals(text, "started <now>", t)
+	Equals(strings.Contains(html, "<pre>started &lt;now&gt;</pre>"), true, t)
+}
@@ -21,6 +21,21 @@ func PopulateBooleanFromEnvironment(name string, variable *bool) bool {
 	return true
 }
 
+func PopulateIntegerFromEnvironment(name string, variable *int) bool {
+	value, present := os.LookupEnv(name)
+	if !present {
+		return false
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return false
+	}
+
+	*variable = v
+	return true
+}
+
 func PopulateStringFromEnvironment(name string, variable *string) bool {
 	value, present := os.LookupEnv(name)
 	if !present {
@@ -47,6 +62,22 @@ func PopulateRepositoriesFromEnvironment(name string, variable *[]Repository) bo
 	return true
 }
 
+func PopulateNotifiersFromEnvironment(name string, variable *[]NotifierConfig) bool {
+	value, present := os.LookupEnv(name)
+	if !present {
+		return false
+	}
+
+	notifiers := make([]NotifierConfig, 0)
+	err := yaml.Unmarshal([]byte(value), &notifiers)
+	if err != nil {
+		return false
+	}
+
+	*variable = notifiers
+	return true
+}
+
 func PopulateDurationFromEnvironment(name string, variable *Duration) bool {
 	value, present := os.LookupEnv(name)
 	if !present {
@@ -53,6 +53,32 @@ func TestPopulateBooleanFromEnvironment(t *testing.T) {
 	Equals(success, true, t)
 }
 
+func TestPopulateIntegerFromEnvironment_NonExisting(t *testing.T) {
+	test := 3
+	success := PopulateIntegerFromEnvironment(NonExisting, &test)
+
+	Equals(test, 3, t)
+	Equals(success, false, t)
+}
+
+func TestPopulateIntegerFromEnvironment_VariableUnparsable(t *testing.T) {
+	_ = os.Setenv(Existing, "unparsable")
+	test := 3
+	success := PopulateIntegerFromEnvironment(Existing, &test)
+
+	Equals(test, 3, t)
+	Equals(success, false, t)
+}
+
+func TestPopulateIntegerFromEnvironment(t *testing.T) {
+	_ = os.Setenv(Existing, "5")
+	test := 3
+	success := PopulateIntegerFromEnvironment(Existing, &test)
+
+	Equals(test, 5, t)
+	Equals(success, true, t)
+}
+
 func TestPopulateStringFromEnvironment_NonExisting(t *testing.T) {
 	test := "startValue"
 	success := PopulateStringFromEnvironment(NonExisting, &test)
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// fetchAllRepositories fetches the repositories with at most
+// config.Concurrency fetches at the same time and sends the contents to be
+// checked for updates. It returns once every repository has been handled or
+// the context is cancelled.
+func fetchAllRepositories(ctx context.Context, config Config, cache *IndexCache, repositoriesToCheckForUpdates chan<- *RepositoryContents) {
+	concurrency := config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := true
+	slots := make(chan struct{}, concurrency)
+	for _, repo := range config.Repositories {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+
+		wg.Add(1)
+		go func(repo Repository) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			repoContents, err := fetchWithTimeout(ctx, config, cache, repo)
+			if err != nil {
+				log.Println("Could not fetch contents for", repo.URL, err)
+				mutex.Lock()
+				succeeded = false
+				mutex.Unlock()
+				return
+			}
+
+			for chart, versions := range repoContents.Versions {
+				if latest := latestVersion(versions); latest != nil {
+					metrics.SetLatestVersion(repo.URL, chart, latest)
+				}
+			}
+
+			select {
+			case repositoriesToCheckForUpdates <- repoContents:
+			case <-ctx.Done():
+			}
+		}(repo)
+	}
+	wg.Wait()
+
+	if succeeded && ctx.Err() == nil {
+		metrics.CheckSucceeded(time.Now())
+	}
+}
+
+// fetchWithTimeout fetches a single repository within config.FetchTimeout and
+// records the metrics of the fetch.
+func fetchWithTimeout(ctx context.Context, config Config, cache *IndexCache, repo Repository) (*RepositoryContents, error) {
+	if config.FetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.FetchTimeout.Duration())
+		defer cancel()
+	}
+
+	start := time.Now()
+	repoContents, err := fetchRepositoryContents(ctx, cache, repo)
+	metrics.ObserveFetch(repo.URL, time.Since(start), err)
+
+	return repoContents, err
+}
+
+func fetchRepositoryContents(ctx context.Context, cache *IndexCache, repo Repository) (*RepositoryContents, error) {
+	var err error
+	client := repo.client
+	if client == nil {
+		client, err = repo.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		defer client.CloseIdleConnections()
+	}
+
+	var repoContents *RepositoryContents
+	if IsOCIRepository(repo.URL) {
+		repoContents, err = fetchOCIRepositoryContents(ctx, client, repo)
+	} else {
+		repoContents, err = fetchIndexRepositoryContents(ctx, client, cache, repo)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repoContents.FilterVersions(repo.ChartsWithDefaults())
+	return repoContents, nil
+}
+
+func fetchOCIRepositoryContents(ctx context.Context, client *http.Client, repo Repository) (*RepositoryContents, error) {
+	registry, err := NewOCIRegistry(repo)
+	if err != nil {
+		return nil, err
+	}
+	registry.Client = client
+
+	return registry.FetchRepositoryContents(ctx, repo)
+}
+
+func fetchIndexRepositoryContents(ctx context.Context, client *http.Client, cache *IndexCache, repo Repository) (*RepositoryContents, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repo.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.Auth.Apply(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cached, isCached := cache.Get(repo)
+	if isCached {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && isCached {
+		log.Println("Index not modified:", repo.URL)
+		repoContents := cached.Contents(repo.URL)
+		repoContents.EntriesToVersions()
+		return repoContents, nil
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, errors.New("Unable to fetch: " + repo.URL)
+	}
+
+	// Servers that do not support conditional requests still allow using the
+	// cached entries when the index was not generated again. Only indexes that
+	// put generated before the entries are not read completely.
+	unchangedGenerated := ""
+	if isCached {
+		unchangedGenerated = cached.Generated
+	}
+
+	repoContents, err := ParseIndex(resp.Body, chartNames(repo.Charts), unchangedGenerated)
+	if errors.Is(err, ErrIndexNotRegenerated) {
+		log.Println("Index not regenerated:", repo.URL)
+		cached.ETag = resp.Header.Get("ETag")
+		cached.LastModified = resp.Header.Get("Last-Modified")
+		cache.Put(repo, cached)
+
+		repoContents = cached.Contents(repo.URL)
+		repoContents.EntriesToVersions()
+		return repoContents, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	repoContents.URL = repo.URL
+	cache.Put(repo, CachedIndex{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Generated:    repoContents.Generated,
+		Entries:      repoContents.Entries,
+	})
+
+	repoContents.EntriesToVersions()
+	return repoContents, nil
+}
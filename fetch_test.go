@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func repositoriesFor(url string, n int) []Repository {
+	repos := make([]Repository, n)
+	for i := range repos {
+		repos[i] = Repository{
+			URL:    url + "/fakechart.yaml?repo=" + string(rune('a'+i)),
+			Charts: []Chart{{Name: "test"}},
+		}
+	}
+
+	return repos
+}
+
+func TestFetchAllRepositories_BoundedConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		http.ServeFile(w, r, "fakechart.yaml")
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	config := Config{Repositories: repositoriesFor(server.URL, 6), Concurrency: 2}
+	results := make(chan *RepositoryContents, 6)
+
+	fetchAllRepositories(context.Background(), config, nil, results)
+
+	Equals(len(results), 6, t)
+	Equals(maxRunning <= 2, true, t)
+	Equals((<-results).Versions["test"][2].String(), "4.5.10", t)
+}
+
+func TestFetchAllRepositories_Timeout(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("repo") == "a" {
+			select {
+			case <-hung:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	config := Config{Repositories: repositoriesFor(server.URL, 2), Concurrency: 2, FetchTimeout: Duration(50 * time.Millisecond)}
+	results := make(chan *RepositoryContents, 2)
+
+	fetchAllRepositories(context.Background(), config, nil, results)
+
+	Equals(len(results), 1, t)
+}
+
+func TestFetchAllRepositories_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	config := Config{Repositories: repositoriesFor(server.URL, 3), Concurrency: 1}
+	// Nobody reads the results, so only cancelling the context ends the fetch.
+	results := make(chan *RepositoryContents)
+	done := make(chan struct{})
+	go func() {
+		fetchAllRepositories(ctx, config, nil, results)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("fetching did not stop after cancelling the context")
+	}
+}
+
+func TestFetchRepositoryContents_Index(t *testing.T) {
+	server := fakeIndexServer()
+	defer server.Close()
+
+	contents, err := fetchRepositoryContents(context.Background(), nil, Repository{
+		URL:    server.URL + "/fakechart.yaml",
+		Charts: []Chart{{Name: "test", Constraint: "<4.5.10"}},
+	})
+
+	Equals(err, nil, t)
+	MapsEqual(versionStrings(contents.Versions["test"]), []string{"4.5.2", "4.5.3"}, t)
+}
+
+func TestFetchRepositoryContents_BadRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("apiVersion: v1\nentries: {}\n"))
+	}))
+	defer server.Close()
+
+	_, err := fetchRepositoryContents(context.Background(), nil, Repository{URL: server.URL + "/index.yaml", Charts: []Chart{{Name: "test"}}})
+
+	Equals(err != nil, true, t)
+}
+
+func TestFetchRepositoryContents_Authenticated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, _ := r.BasicAuth(); username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+
+	repo := Repository{URL: server.URL + "/fakechart.yaml", Charts: []Chart{{Name: "test"}}}
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err != nil, true, t)
+
+	repo.Auth = &RepositoryAuth{Username: "user", Password: &Secret{Value: "pass"}}
+	contents, err := fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err, nil, t)
+	Equals(len(contents.Versions["test"]), 3, t)
+}
@@ -4,6 +4,9 @@ go 1.19
 
 require (
 	github.com/Masterminds/semver v1.5.0
+	go.etcd.io/bbolt v1.3.7
 	gopkg.in/yaml.v2 v2.4.0
 	sigs.k8s.io/yaml v1.4.0
 )
+
+require golang.org/x/sys v0.4.0 // indirect
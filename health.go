@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health keeps track of the check cycles to answer liveness and readiness
+// probes. The monitor is ready once a cycle completed and it is live as long as
+// a cycle completed within maxAge, or within maxAge of starting.
+type Health struct {
+	mutex     sync.Mutex
+	started   time.Time
+	lastCycle time.Time
+	maxAge    time.Duration
+	now       func() time.Time
+}
+
+func NewHealth(maxAge time.Duration) *Health {
+	return &Health{
+		started: time.Now(),
+		maxAge:  maxAge,
+		now:     time.Now,
+	}
+}
+
+func (h *Health) CycleCompleted() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.lastCycle = h.now()
+}
+
+func (h *Health) Ready() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return !h.lastCycle.IsZero()
+}
+
+func (h *Health) Live() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.lastCycle.IsZero() {
+		if age := h.now().Sub(h.started); age > h.maxAge {
+			return fmt.Errorf("no check cycle completed since starting %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+
+	if age := h.now().Sub(h.lastCycle); age > h.maxAge {
+		return fmt.Errorf("last check cycle completed %s ago", age.Round(time.Second))
+	}
+
+	return nil
+}
+
+func (h *Health) ServeLiveness(w http.ResponseWriter, _ *http.Request) {
+	err := h.Live()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+func (h *Health) ServeReadiness(w http.ResponseWriter, _ *http.Request) {
+	if !h.Ready() {
+		http.Error(w, "no check cycle completed yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func testHealth(now *time.Time) *Health {
+	h := NewHealth(time.Hour)
+	h.started = *now
+	h.now = func() time.Time { return *now }
+
+	return h
+}
+
+func TestHealth_Ready(t *testing.T) {
+	now := time.Now()
+	h := testHealth(&now)
+
+	Equals(h.Ready(), false, t)
+
+	h.CycleCompleted()
+
+	Equals(h.Ready(), true, t)
+}
+
+func TestHealth_Live_BeforeFirstCycle(t *testing.T) {
+	now := time.Now()
+	h := testHealth(&now)
+
+	Equals(h.Live(), nil, t)
+
+	now = now.Add(2 * time.Hour)
+
+	Equals(h.Live().Error(), "no check cycle completed since starting 2h0m0s ago", t)
+}
+
+func TestHealth_Live(t *testing.T) {
+	now := time.Now()
+	h := testHealth(&now)
+	h.CycleCompleted()
+
+	now = now.Add(30 * time.Minute)
+	Equals(h.Live(), nil, t)
+
+	now = now.Add(time.Hour)
+	Equals(h.Live().Error(), "last check cycle completed 1h30m0s ago", t)
+
+	h.CycleCompleted()
+	Equals(h.Live(), nil, t)
+}
+
+func TestHealth_ServeReadiness(t *testing.T) {
+	now := time.Now()
+	h := testHealth(&now)
+
+	recorder := httptest.NewRecorder()
+	h.ServeReadiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	Equals(recorder.Code, 503, t)
+
+	h.CycleCompleted()
+	recorder = httptest.NewRecorder()
+	h.ServeReadiness(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	Equals(recorder.Code, 200, t)
+}
+
+func TestHealth_ServeLiveness(t *testing.T) {
+	now := time.Now()
+	h := testHealth(&now)
+
+	recorder := httptest.NewRecorder()
+	h.ServeLiveness(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	Equals(recorder.Code, 200, t)
+
+	now = now.Add(2 * time.Hour)
+	recorder = httptest.NewRecorder()
+	h.ServeLiveness(recorder, httptest.NewRequest("GET", "/healthz", nil))
+	Equals(recorder.Code, 503, t)
+}
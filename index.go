@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrIndexNotRegenerated is returned by ParseIndex when the index has the
+// generated timestamp it was told to look for.
+var ErrIndexNotRegenerated = errors.New("index was not generated again")
+
+// ParseIndex streams an index in either YAML or JSON format and only
+// materialises the entries of the given charts, so large indexes never have to
+// be held in memory completely. Parsing stops with ErrIndexNotRegenerated as
+// soon as a generated timestamp equal to unchangedGenerated is found. Helm
+// writes generated after the entries, so for indexes generated by helm that
+// only happens once the whole index was read, and only saves keeping the
+// parsed entries.
+func ParseIndex(r io.Reader, charts []ChartName, unchangedGenerated string) (*RepositoryContents, error) {
+	wanted := make(map[ChartName]bool, len(charts))
+	for _, c := range charts {
+		wanted[c] = true
+	}
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &indexParser{
+		wanted:             wanted,
+		unchangedGenerated: unchangedGenerated,
+		contents:           &RepositoryContents{Entries: make(map[ChartName][]Entry)},
+	}
+	if first == '{' {
+		err = p.parseJSON(br)
+	} else {
+		err = p.parseYAML(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.contents, nil
+}
+
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for i := 1; ; i++ {
+		peeked, err := br.Peek(i)
+		if len(peeked) < i {
+			if err == io.EOF {
+				return 0, errors.New("the index is empty")
+			}
+			return 0, err
+		}
+
+		b := peeked[i-1]
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, nil
+		}
+	}
+}
+
+type indexParser struct {
+	wanted             map[ChartName]bool
+	unchangedGenerated string
+	contents           *RepositoryContents
+}
+
+func (p *indexParser) setGenerated(generated string) error {
+	p.contents.Generated = generated
+	if generated != "" && generated == p.unchangedGenerated {
+		return ErrIndexNotRegenerated
+	}
+
+	return nil
+}
+
+// parseYAML relies on the structure of the indexes helm generates: entries is
+// a top level mapping from chart name to a list of entries. The lines of a
+// wanted chart are collected and unmarshalled on their own, all other lines
+// are skipped. Entries written inline, such as entries: {nginx: [...]}, have
+// to fit on their line.
+func (p *indexParser) parseYAML(br *bufio.Reader) error {
+	inEntries := false
+	chartIndent := -1
+	var chart ChartName
+	var collected *bytes.Buffer
+
+	flush := func() error {
+		if collected == nil {
+			return nil
+		}
+
+		var block struct {
+			Entries []Entry `yaml:"entries"`
+		}
+		err := yaml.Unmarshal(collected.Bytes(), &block)
+		if err != nil {
+			return fmt.Errorf("could not parse the entries of %s: %w", chart, err)
+		}
+
+		p.contents.Entries[chart] = block.Entries
+		collected = nil
+		return nil
+	}
+
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		trimmed := strings.TrimRight(line, "\r\n")
+		content := strings.TrimLeft(trimmed, " ")
+		indent := len(trimmed) - len(content)
+
+		switch {
+		case content == "" || strings.HasPrefix(content, "#"):
+			if collected != nil {
+				collected.WriteString(trimmed + "\n")
+			}
+		case indent == 0:
+			err := flush()
+			if err != nil {
+				return err
+			}
+
+			key, value := splitYAMLKey(content)
+			inEntries = key == "entries"
+			if inEntries && value != "" && !strings.HasPrefix(value, "#") {
+				inEntries = false
+				err = p.parseInlineEntries(content)
+				if err != nil {
+					return err
+				}
+			}
+			if key == "generated" {
+				err = p.setGenerated(unquoteYAML(value))
+				if err != nil {
+					return err
+				}
+			}
+		case !inEntries:
+		case chartIndent == -1 || (indent == chartIndent && !strings.HasPrefix(content, "-")):
+			err := flush()
+			if err != nil {
+				return err
+			}
+
+			chartIndent = indent
+			key, value := splitYAMLKey(content)
+			chart = ChartName(key)
+			if p.wanted[chart] {
+				collected = bytes.NewBufferString("entries: " + value + "\n")
+			}
+		case collected != nil:
+			collected.WriteString(trimmed + "\n")
+		}
+
+		if readErr == io.EOF {
+			return flush()
+		}
+	}
+}
+
+func (p *indexParser) parseInlineEntries(line string) error {
+	var index struct {
+		Entries map[ChartName][]Entry `yaml:"entries"`
+	}
+	err := yaml.Unmarshal([]byte(line), &index)
+	if err != nil {
+		return fmt.Errorf("could not parse the inline entries of the index: %w", err)
+	}
+
+	for chart, entries := range index.Entries {
+		if p.wanted[chart] {
+			p.contents.Entries[chart] = entries
+		}
+	}
+
+	return nil
+}
+
+// splitYAMLKey splits a line such as "name: value" or "'name':" in its key and
+// value.
+func splitYAMLKey(content string) (string, string) {
+	if strings.HasPrefix(content, `"`) || strings.HasPrefix(content, `'`) {
+		quote := content[:1]
+		end := strings.Index(content[1:], quote)
+		if end != -1 {
+			rest := strings.TrimPrefix(content[end+2:], ":")
+			return content[1 : end+1], strings.TrimSpace(rest)
+		}
+	}
+
+	key, value, found := strings.Cut(content, ":")
+	if !found {
+		return "", ""
+	}
+
+	return strings.TrimSpace(key), strings.TrimSpace(value)
+}
+
+func unquoteYAML(value string) string {
+	var unquoted string
+	if yaml.Unmarshal([]byte(value), &unquoted) != nil {
+		return value
+	}
+
+	return unquoted
+}
+
+func (p *indexParser) parseJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	err := expectDelim(dec, '{')
+	if err != nil {
+		return err
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "entries":
+			err = p.parseJSONEntries(dec)
+		case "generated":
+			var generated string
+			err = dec.Decode(&generated)
+			if err == nil {
+				err = p.setGenerated(generated)
+			}
+		default:
+			err = skipJSONValue(dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *indexParser) parseJSONEntries(dec *json.Decoder) error {
+	err := expectDelim(dec, '{')
+	if err != nil {
+		return err
+	}
+
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		name, _ := token.(string)
+		chart := ChartName(name)
+		if !p.wanted[chart] {
+			err = skipJSONValue(dec)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		entries := make([]Entry, 0)
+		err = dec.Decode(&entries)
+		if err != nil {
+			return fmt.Errorf("could not parse the entries of %s: %w", chart, err)
+		}
+		p.contents.Entries[chart] = entries
+	}
+
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("expected %s in index, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// skipJSONValue skips the next value without materialising it.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const yamlIndex = `apiVersion: v1
+entries:
+  skipped:
+  - version: 9.9.9
+    description: |
+      generated: not the real one
+  "wanted":
+  - apiVersion: v2
+    version: 1.0.0
+    urls:
+    - https://example.com/wanted-1.0.0.tgz
+
+  - version: 1.1.0
+    appVersion: "2.1"
+    home: https://example.com/wanted
+    sources:
+    - https://github.com/example/wanted
+  inline: [{version: 2.0.0}]
+generated: "2023-01-01T00:00:00Z"
+`
+
+func TestParseIndex_YAML(t *testing.T) {
+	contents, err := ParseIndex(strings.NewReader(yamlIndex), []ChartName{"wanted", "inline", "missing"}, "")
+
+	Equals(err, nil, t)
+	Equals(len(contents.Entries), 2, t)
+	MapsEqual(contents.Entries["wanted"], []Entry{
+		{Version: "1.0.0"},
+		{Version: "1.1.0", AppVersion: "2.1", Home: "https://example.com/wanted", Sources: []string{"https://github.com/example/wanted"}},
+	}, t)
+	MapsEqual(contents.Entries["inline"], []Entry{{Version: "2.0.0"}}, t)
+	Equals(contents.Generated, "2023-01-01T00:00:00Z", t)
+
+	// Entries written inline instead of as a block.
+	index := "apiVersion: v1\nentries: {nginx: [{version: 1.0.0}], skipped: [{version: 9.9.9}]}\ngenerated: \"2023-01-01T00:00:00Z\"\n"
+	contents, err = ParseIndex(strings.NewReader(index), []ChartName{"nginx"}, "")
+	Equals(err, nil, t)
+	Equals(len(contents.Entries), 1, t)
+	MapsEqual(contents.Entries["nginx"], []Entry{{Version: "1.0.0"}}, t)
+	Equals(contents.Generated, "2023-01-01T00:00:00Z", t)
+
+	// Inline entries spanning several lines are rejected rather than skipped.
+	_, err = ParseIndex(strings.NewReader("apiVersion: v1\nentries: {nginx: [\n  {version: 1.0.0}]}\n"), []ChartName{"nginx"}, "")
+	Equals(err != nil, true, t)
+}
+
+func TestParseIndex_FakeChart(t *testing.T) {
+	index, _ := os.Open("fakechart.yaml")
+	defer index.Close()
+
+	contents, err := ParseIndex(index, []ChartName{"test"}, "")
+
+	Equals(err, nil, t)
+	MapsEqual(contents.Entries["test"], []Entry{{Version: "4.5.2"}, {Version: "4.5.3"}, {Version: "4.5.10"}}, t)
+	Equals(contents.Generated, "2022-08-02T14:06:28.079863412Z", t)
+}
+
+func TestParseIndex_JSON(t *testing.T) {
+	index := `{
+  "apiVersion": "v1",
+  "generated": "2023-01-01T00:00:00Z",
+  "entries": {
+    "skipped": [{"version": "9.9.9", "keywords": ["a", {"b": [1, 2]}]}],
+    "wanted": [{"version": "1.0.0", "urls": ["https://example.com"]}, {"version": "1.1.0", "description": "A wanted chart"}]
+  }
+}`
+
+	contents, err := ParseIndex(strings.NewReader(index), []ChartName{"wanted"}, "")
+
+	Equals(err, nil, t)
+	Equals(len(contents.Entries), 1, t)
+	MapsEqual(contents.Entries["wanted"], []Entry{{Version: "1.0.0"}, {Version: "1.1.0", Description: "A wanted chart"}}, t)
+	Equals(contents.Generated, "2023-01-01T00:00:00Z", t)
+}
+
+func TestParseIndex_NotRegenerated(t *testing.T) {
+	_, err := ParseIndex(strings.NewReader(yamlIndex), []ChartName{"wanted"}, "2023-01-01T00:00:00Z")
+	Equals(errors.Is(err, ErrIndexNotRegenerated), true, t)
+
+	_, err = ParseIndex(strings.NewReader(`{"generated": "2023-01-01T00:00:00Z", "entries": {}}`), nil, "2023-01-01T00:00:00Z")
+	Equals(errors.Is(err, ErrIndexNotRegenerated), true, t)
+}
+
+func TestParseIndex_Invalid(t *testing.T) {
+	_, err := ParseIndex(strings.NewReader(""), nil, "")
+	ErrorsEqual(err, errors.New("the index is empty"), t)
+
+	_, err = ParseIndex(strings.NewReader(`{"entries": {"wanted": {"version": 1}}}`), []ChartName{"wanted"}, "")
+	Equals(err != nil, true, t)
+
+	_, err = ParseIndex(strings.NewReader("entries:\n  wanted:\n  - version: [\n"), []ChartName{"wanted"}, "")
+	Equals(err != nil, true, t)
+}
+
+func TestSplitYAMLKey(t *testing.T) {
+	for _, test := range []struct{ line, key, value string }{
+		{"chart:", "chart", ""},
+		{"chart: []", "chart", "[]"},
+		{`"chart:name": []`, "chart:name", "[]"},
+		{"'chart':", "chart", ""},
+		{"no key", "", ""},
+	} {
+		key, value := splitYAMLKey(test.line)
+		Equals(key, test.key, t)
+		Equals(value, test.value, t)
+	}
+}
+
+type benchmarkEntry struct {
+	APIVersion  string   `yaml:"apiVersion" json:"apiVersion"`
+	Version     string   `yaml:"version" json:"version"`
+	AppVersion  string   `yaml:"appVersion" json:"appVersion"`
+	Description string   `yaml:"description" json:"description"`
+	Digest      string   `yaml:"digest" json:"digest"`
+	URLs        []string `yaml:"urls" json:"urls"`
+}
+
+type benchmarkIndex struct {
+	APIVersion string                      `yaml:"apiVersion" json:"apiVersion"`
+	Entries    map[string][]benchmarkEntry `yaml:"entries" json:"entries"`
+	Generated  string                      `yaml:"generated" json:"generated"`
+}
+
+// syntheticIndex returns an index of 1000 charts with 50 versions each, which
+// is in the same order of magnitude as the largest public indexes.
+func syntheticIndex(b *testing.B, marshal func(any) ([]byte, error)) []byte {
+	index := benchmarkIndex{APIVersion: "v1", Entries: make(map[string][]benchmarkEntry), Generated: "2023-01-01T00:00:00Z"}
+	for c := 0; c < 1000; c++ {
+		name := fmt.Sprintf("chart-%04d", c)
+		for v := 0; v < 50; v++ {
+			version := fmt.Sprintf("1.%d.%d", v/10, v%10)
+			index.Entries[name] = append(index.Entries[name], benchmarkEntry{
+				APIVersion:  "v2",
+				Version:     version,
+				AppVersion:  version,
+				Description: strings.Repeat("A chart that is used to benchmark index parsing. ", 4),
+				Digest:      strings.Repeat("0123456789abcdef", 4),
+				URLs:        []string{"https://charts.example.com/" + name + "-" + version + ".tgz"},
+			})
+		}
+	}
+
+	data, err := marshal(index)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return data
+}
+
+var benchmarkCharts = []ChartName{"chart-0010", "chart-0500"}
+
+func BenchmarkParseIndex_YAML(b *testing.B) {
+	index := syntheticIndex(b, yaml.Marshal)
+	b.SetBytes(int64(len(index)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := ParseIndex(bytes.NewReader(index), benchmarkCharts, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseIndex_JSON(b *testing.B) {
+	index := syntheticIndex(b, json.Marshal)
+	b.SetBytes(int64(len(index)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := ParseIndex(bytes.NewReader(index), benchmarkCharts, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkUnmarshalIndex measures unmarshalling the complete index and
+// filtering the charts afterwards, which ParseIndex replaces.
+func BenchmarkUnmarshalIndex(b *testing.B) {
+	index := syntheticIndex(b, yaml.Marshal)
+	b.SetBytes(int64(len(index)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var contents RepositoryContents
+		err := yaml.Unmarshal(index, &contents)
+		if err != nil {
+			b.Fatal(err)
+		}
+		contents.FilterCharts([]Chart{{Name: benchmarkCharts[0]}, {Name: benchmarkCharts[1]}})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+// metrics collects the metrics of the monitor and exposes them in the
+// Prometheus text format.
+var metrics = NewMetrics()
+
+type chartKey struct {
+	Repository string
+	Chart      ChartName
+}
+
+type Metrics struct {
+	mutex               sync.Mutex
+	latestVersions      map[chartKey]string
+	fetches             map[string]float64
+	fetchFailures       map[string]float64
+	fetchDurations      map[string]float64
+	notificationsSent   map[string]float64
+	notificationsFailed map[string]float64
+	unroutedReports     map[string]float64
+	lastSuccessfulCheck time.Time
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		latestVersions:      make(map[chartKey]string),
+		fetches:             make(map[string]float64),
+		fetchFailures:       make(map[string]float64),
+		fetchDurations:      make(map[string]float64),
+		notificationsSent:   make(map[string]float64),
+		notificationsFailed: make(map[string]float64),
+		unroutedReports:     make(map[string]float64),
+	}
+}
+
+func (m *Metrics) ObserveFetch(repository string, duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.fetches[repository]++
+	m.fetchDurations[repository] = duration.Seconds()
+	if err != nil {
+		m.fetchFailures[repository]++
+	}
+}
+
+func (m *Metrics) SetLatestVersion(repository string, chart ChartName, version *semver.Version) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.latestVersions[chartKey{Repository: repository, Chart: chart}] = version.String()
+}
+
+func (m *Metrics) ObserveNotification(notifier string, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err != nil {
+		m.notificationsFailed[notifier]++
+		return
+	}
+	m.notificationsSent[notifier]++
+}
+
+// ObserveUnroutedReport counts a report that no notifier received.
+func (m *Metrics) ObserveUnroutedReport(repository string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.unroutedReports[repository]++
+}
+
+func (m *Metrics) CheckSucceeded(at time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.lastSuccessfulCheck = at
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	b := &strings.Builder{}
+
+	writeHeader(b, "chart_version_monitor_latest_version_info", "gauge", "Latest version of a monitored chart.")
+	keys := make([]chartKey, 0, len(m.latestVersions))
+	for k := range m.latestVersions {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Repository != keys[j].Repository {
+			return keys[i].Repository < keys[j].Repository
+		}
+		return keys[i].Chart < keys[j].Chart
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "chart_version_monitor_latest_version_info{repository=%s,chart=%s,version=%s} 1\n",
+			quoteLabel(k.Repository), quoteLabel(string(k.Chart)), quoteLabel(m.latestVersions[k]))
+	}
+
+	writeSamples(b, "chart_version_monitor_fetches_total", "counter", "Number of times a repository was fetched.", "repository", m.fetches)
+	writeSamples(b, "chart_version_monitor_fetch_failures_total", "counter", "Number of times fetching a repository failed.", "repository", m.fetchFailures)
+	writeSamples(b, "chart_version_monitor_fetch_duration_seconds", "gauge", "Duration of the last fetch of a repository.", "repository", m.fetchDurations)
+	writeSamples(b, "chart_version_monitor_notifications_sent_total", "counter", "Number of notifications sent by a notifier.", "notifier", m.notificationsSent)
+	writeSamples(b, "chart_version_monitor_notifications_failed_total", "counter", "Number of notifications a notifier failed to send.", "notifier", m.notificationsFailed)
+	writeSamples(b, "chart_version_monitor_unrouted_reports_total", "counter", "Number of reports no notifier was routed to.", "repository", m.unroutedReports)
+
+	writeHeader(b, "chart_version_monitor_last_successful_check_timestamp_seconds", "gauge", "Time the last check of all repositories succeeded.")
+	lastSuccessfulCheck := float64(0)
+	if !m.lastSuccessfulCheck.IsZero() {
+		lastSuccessfulCheck = float64(m.lastSuccessfulCheck.UnixNano()) / float64(time.Second)
+	}
+	fmt.Fprintf(b, "chart_version_monitor_last_successful_check_timestamp_seconds %g\n", lastSuccessfulCheck)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeSamples(b *strings.Builder, name, kind, help, label string, samples map[string]float64) {
+	writeHeader(b, name, kind, help)
+
+	values := make([]string, 0, len(samples))
+	for v := range samples {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		fmt.Fprintf(b, "%s{%s=%s} %g\n", name, label, quoteLabel(v), samples[v])
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+func TestMetrics_WriteTo(t *testing.T) {
+	m := NewMetrics()
+	m.SetLatestVersion("https://example.com/index.yaml", "chart", semver.MustParse("1.0.0"))
+	m.SetLatestVersion("https://example.com/index.yaml", "chart", semver.MustParse("1.1.0"))
+	m.ObserveFetch("https://example.com/index.yaml", 1500*time.Millisecond, nil)
+	m.ObserveFetch("https://example.com/index.yaml", 500*time.Millisecond, errors.New("unavailable"))
+	m.ObserveNotification("team", nil)
+	m.ObserveNotification("team", errors.New("unavailable"))
+	m.CheckSucceeded(time.Unix(1700000000, 0))
+
+	out := &strings.Builder{}
+	_, err := m.WriteTo(out)
+	Equals(err, nil, t)
+
+	for _, expected := range []string{
+		`chart_version_monitor_latest_version_info{repository="https://example.com/index.yaml",chart="chart",version="1.1.0"} 1`,
+		`chart_version_monitor_fetches_total{repository="https://example.com/index.yaml"} 2`,
+		`chart_version_monitor_fetch_failures_total{repository="https://example.com/index.yaml"} 1`,
+		`chart_version_monitor_fetch_duration_seconds{repository="https://example.com/index.yaml"} 0.5`,
+		`chart_version_monitor_notifications_sent_total{notifier="team"} 1`,
+		`chart_version_monitor_notifications_failed_total{notifier="team"} 1`,
+		`chart_version_monitor_last_successful_check_timestamp_seconds 1.7e+09`,
+		`# TYPE chart_version_monitor_fetches_total counter`,
+	} {
+		if !strings.Contains(out.String(), expected+"\n") {
+			t.Errorf("metrics do not contain %s:\n%s", expected, out)
+		}
+	}
+
+	Equals(strings.Contains(out.String(), `version="1.0.0"`), false, t)
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	NewMetrics().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	Equals(recorder.Code, 200, t)
+	Equals(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8", t)
+	Equals(strings.Contains(recorder.Body.String(), "chart_version_monitor_last_successful_check_timestamp_seconds 0\n"), true, t)
+}
+
+func TestQuoteLabel(t *testing.T) {
+	Equals(quoteLabel("a\"b\\c\nd"), `"a\"b\\c\nd"`, t)
+}
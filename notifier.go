@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	NotifierTypeSlack   = "slack"
+	NotifierTypeWebhook = "webhook"
+	NotifierTypeStdout  = "stdout"
+	NotifierTypeTeams   = "teams"
+	NotifierTypeDiscord = "discord"
+	NotifierTypeEmail   = "email"
+)
+
+// DefaultNotifierName is the name of the Slack notifier that is created for the
+// webhook_url setting.
+const DefaultNotifierName = "default"
+
+// notifierTimeout limits how long a single notification may take.
+const notifierTimeout = 30 * time.Second
+
+// Notification is what gets sent to a Notifier. It either contains a plain
+// text, such as the start message, or the reports of updated charts.
+type Notification struct {
+	Text    string   `json:"text,omitempty"`
+	Reports []Report `json:"reports,omitempty"`
+	// Nonce makes a text notification unique, so the same text sent twice is
+	// not mistaken for a retry.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// NewTextNotification returns a notification with a unique ID for the text.
+func NewTextNotification(text string) Notification {
+	return Notification{Text: text, Nonce: newNonce()}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ID identifies the notification by its contents.
+func (n Notification) ID() string {
+	if n.Nonce != "" {
+		return hashOf(n.Text, n.Nonce)
+	}
+
+	ids := make([]string, len(n.Reports))
+	for i, r := range n.Reports {
+		ids[i] = r.ID()
+	}
+
+	return hashOf(n.Text, strings.Join(ids, ","))
+}
+
+func (n Notification) String() string {
+	if n.Text != "" {
+		return n.Text
+	}
+
+	messages := make([]string, len(n.Reports))
+	for i, r := range n.Reports {
+		messages[i] = r.Message()
+	}
+
+	return strings.Join(messages, "\n\n")
+}
+
+// Notifier delivers notifications to a destination such as a Slack channel.
+type Notifier interface {
+	Name() string
+	Notify(notification Notification) error
+}
+
+type NotifierConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	URL  string `json:"url,omitempty"`
+	// Template is the text/template used for the messages of reports. It
+	// overrides the global template.
+	Template string `json:"template,omitempty"`
+	// Email contains the settings of email notifiers.
+	Email *EmailConfig `json:"email,omitempty"`
+	// Secret is used by webhook notifiers to sign their payloads.
+	Secret *Secret `json:"secret,omitempty"`
+	// Digest is the schedule at which the notifier receives the reports,
+	// instead of right away.
+	Digest string `json:"digest,omitempty"`
+	// DeliveryWindow limits when the notifier delivers reports, such as
+	// "every weekday 08:00-18:00 Europe/Amsterdam". Reports are held until
+	// the window opens.
+	DeliveryWindow string `json:"delivery_window,omitempty"`
+}
+
+func (nc NotifierConfig) Validate() error {
+	if nc.Name == "" {
+		return errors.New("the notifier name should not be empty")
+	}
+
+	switch nc.Type {
+	case NotifierTypeSlack, NotifierTypeWebhook, NotifierTypeTeams, NotifierTypeDiscord:
+		if nc.URL == "" {
+			return fmt.Errorf("notifier %s requires a url", nc.Name)
+		}
+	case NotifierTypeEmail:
+		err := nc.Email.Validate()
+		if err != nil {
+			return fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+	case NotifierTypeStdout:
+	default:
+		return fmt.Errorf("notifier %s has unknown type %s", nc.Name, nc.Type)
+	}
+
+	err := nc.Secret.Validate()
+	if err != nil {
+		return fmt.Errorf("notifier %s: %w", nc.Name, err)
+	}
+
+	if nc.Digest != "" {
+		_, err = ParseSchedule(nc.Digest)
+		if err != nil {
+			return fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+	}
+
+	if nc.DeliveryWindow != "" {
+		_, err = ParseDeliveryWindow(nc.DeliveryWindow)
+		if err != nil {
+			return fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+	}
+
+	if nc.Template != "" {
+		_, err := ParseMessageTemplate(nc.Template)
+		if err != nil {
+			return fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func NewNotifier(nc NotifierConfig) (Notifier, error) {
+	err := nc.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *MessageTemplate
+	if nc.Template != "" {
+		tmpl, err = ParseMessageTemplate(nc.Template)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch nc.Type {
+	case NotifierTypeSlack:
+		n := NewSlackNotifier(nc.Name, nc.URL)
+		n.template = tmpl
+		return n, nil
+	case NotifierTypeWebhook:
+		n := NewWebhookNotifier(nc.Name, nc.URL)
+		n.template = tmpl
+		n.secret = nc.Secret
+		return n, nil
+	case NotifierTypeTeams:
+		n := NewTeamsNotifier(nc.Name, nc.URL)
+		n.template = tmpl
+		return n, nil
+	case NotifierTypeDiscord:
+		n := NewDiscordNotifier(nc.Name, nc.URL)
+		n.template = tmpl
+		return n, nil
+	case NotifierTypeEmail:
+		n := NewEmailNotifier(nc.Name, *nc.Email)
+		n.template = tmpl
+		return n, nil
+	default:
+		n := NewStdoutNotifier(nc.Name, os.Stdout)
+		n.template = tmpl
+		return n, nil
+	}
+}
+
+// NotifyAll sends the notification to every notifier and returns the errors of
+// the ones that failed.
+func NotifyAll(notifiers []Notifier, notification Notification) error {
+	failures := make([]string, 0)
+	for _, n := range notifiers {
+		err := n.Notify(notification)
+		metrics.ObserveNotification(n.Name(), err)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("notifier %s: %s", n.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+type StdoutNotifier struct {
+	name     string
+	out      io.Writer
+	template *MessageTemplate
+}
+
+func NewStdoutNotifier(name string, out io.Writer) *StdoutNotifier {
+	return &StdoutNotifier{name: name, out: out}
+}
+
+func (s *StdoutNotifier) Name() string {
+	return s.name
+}
+
+func (s *StdoutNotifier) Notify(notification Notification) error {
+	text, err := s.template.Render(notification)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(s.out, text)
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+type fakeNotifier struct {
+	name          string
+	err           error
+	notifications []Notification
+}
+
+func (f *fakeNotifier) Name() string {
+	return f.name
+}
+
+func (f *fakeNotifier) Notify(notification Notification) error {
+	f.notifications = append(f.notifications, notification)
+	return f.err
+}
+
+func testReport() Report {
+	return Report{
+		Repository:      "https://example.com/index.yaml",
+		Chart:           "chart",
+		PreviousVersion: semver.MustParse("1.0.0"),
+		NewVersion:      semver.MustParse("1.1.0"),
+		NewVersions:     semver.Collection{semver.MustParse("1.0.1"), semver.MustParse("1.1.0")},
+		Dependees:       []string{"Example"},
+	}
+}
+
+func TestNotification_String_Text(t *testing.T) {
+	n := Notification{Text: "started", Reports: []Report{testReport()}}
+
+	Equals(n.String(), "started", t)
+}
+
+func TestNotification_String_Reports(t *testing.T) {
+	n := Notification{Reports: []Report{testReport(), testReport()}}
+
+	Equals(n.String(), testReport().Message()+"\n\n"+testReport().Message(), t)
+}
+
+func TestNotifierConfig_Validate(t *testing.T) {
+	Equals(NotifierConfig{Name: "console", Type: NotifierTypeStdout}.Validate(), nil, t)
+	Equals(NotifierConfig{Name: "team", Type: NotifierTypeSlack, URL: "https://example.com"}.Validate(), nil, t)
+	ErrorsEqual(NotifierConfig{Type: NotifierTypeStdout}.Validate(), errors.New("the notifier name should not be empty"), t)
+	ErrorsEqual(NotifierConfig{Name: "team", Type: NotifierTypeWebhook}.Validate(), errors.New("notifier team requires a url"), t)
+	ErrorsEqual(NotifierConfig{Name: "team", Type: "pigeon"}.Validate(), errors.New("notifier team has unknown type pigeon"), t)
+	ErrorsEqual(NotifierConfig{Name: "mail", Type: NotifierTypeEmail}.Validate(), errors.New("notifier mail: email settings are missing"), t)
+	Equals(NotifierConfig{Name: "console", Type: NotifierTypeStdout, DeliveryWindow: "every weekday 08:00-18:00"}.Validate(), nil, t)
+	Equals(NotifierConfig{Name: "console", Type: NotifierTypeStdout, DeliveryWindow: "every weekday 08:00"}.Validate() != nil, true, t)
+}
+
+func TestNewNotifier(t *testing.T) {
+	n, err := NewNotifier(NotifierConfig{Name: "team", Type: NotifierTypeSlack, URL: "https://example.com"})
+
+	Equals(err, nil, t)
+	Equals(n.Name(), "team", t)
+	_, ok := n.(*SlackNotifier)
+	Equals(ok, true, t)
+
+	n, err = NewNotifier(NotifierConfig{Name: "teams", Type: NotifierTypeTeams, URL: "https://example.com"})
+	Equals(err, nil, t)
+	_, ok = n.(*TeamsNotifier)
+	Equals(ok, true, t)
+}
+
+func TestNotifyAll(t *testing.T) {
+	first := &fakeNotifier{name: "first", err: errors.New("unavailable")}
+	second := &fakeNotifier{name: "second"}
+
+	err := NotifyAll([]Notifier{first, second}, Notification{Text: "hello"})
+
+	ErrorsEqual(err, errors.New("notifier first: unavailable"), t)
+	Equals(len(first.notifications), 1, t)
+	Equals(len(second.notifications), 1, t)
+}
+
+func TestStdoutNotifier_Notify(t *testing.T) {
+	out := &bytes.Buffer{}
+	n := NewStdoutNotifier("console", out)
+
+	err := n.Notify(Notification{Text: "hello"})
+
+	Equals(err, nil, t)
+	Equals(out.String(), "hello\n", t)
+}
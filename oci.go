@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const ociScheme = "oci://"
+
+func IsOCIRepository(repositoryURL string) bool {
+	return strings.HasPrefix(repositoryURL, ociScheme)
+}
+
+// OCIRegistry lists chart versions from an OCI registry through the tag
+// listing endpoint of the OCI distribution API.
+type OCIRegistry struct {
+	Client *http.Client
+	// Host is the registry host and Namespace the path below which the charts
+	// are published, oci://registry-1.docker.io/bitnamicharts for instance
+	// results in registry-1.docker.io and bitnamicharts.
+	Host      string
+	Namespace string
+	PlainHTTP bool
+	// Auth is used for the registry as well as for its token service.
+	Auth *RepositoryAuth
+}
+
+type ociTagList struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type ociToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+func NewOCIRegistry(repo Repository) (*OCIRegistry, error) {
+	if !IsOCIRepository(repo.URL) {
+		return nil, fmt.Errorf("%s is not an OCI repository", repo.URL)
+	}
+
+	hostAndPath := strings.Trim(strings.TrimPrefix(repo.URL, ociScheme), "/")
+	host, namespace, _ := strings.Cut(hostAndPath, "/")
+	if host == "" {
+		return nil, fmt.Errorf("%s does not contain a registry host", repo.URL)
+	}
+
+	return &OCIRegistry{
+		Client:    http.DefaultClient,
+		Host:      host,
+		Namespace: namespace,
+		PlainHTTP: repo.PlainHTTP,
+		Auth:      repo.Auth,
+	}, nil
+}
+
+func (r *OCIRegistry) FetchRepositoryContents(ctx context.Context, repo Repository) (*RepositoryContents, error) {
+	repoContents := RepositoryContents{
+		URL:     repo.URL,
+		Entries: make(map[ChartName][]Entry),
+	}
+
+	for _, chart := range repo.Charts {
+		tags, err := r.Tags(ctx, chart.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not list tags of %s: %w", chart.Name, err)
+		}
+
+		entries := make([]Entry, 0, len(tags))
+		for _, tag := range tags {
+			// OCI tags can not contain a '+', so helm replaces it with an '_'.
+			entries = append(entries, Entry{Version: strings.ReplaceAll(tag, "_", "+")})
+		}
+		repoContents.Entries[chart.Name] = entries
+	}
+
+	repoContents.EntriesToVersions()
+	return &repoContents, nil
+}
+
+func (r *OCIRegistry) Tags(ctx context.Context, chart ChartName) ([]string, error) {
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+
+	repository := strings.Trim(r.Namespace+"/"+string(chart), "/")
+	next := fmt.Sprintf("%s://%s/v2/%s/tags/list", scheme, r.Host, repository)
+	token := ""
+	tags := make([]string, 0)
+	for next != "" {
+		resp, err := r.get(ctx, next, token, r.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+
+			token, err = r.fetchToken(ctx, challenge)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %d listing tags of %s", resp.StatusCode, repository)
+		}
+
+		var list ociTagList
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, list.Tags...)
+		next, err = nextPage(next, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// get requests the URL, with the credentials and the token only when the URL
+// is on trustedHost. The next pages of a listing may be on another host, which
+// should not receive the credentials of the registry.
+func (r *OCIRegistry) get(ctx context.Context, url, token, trustedHost string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL.Host != trustedHost {
+		return r.Client.Do(req)
+	}
+
+	err = r.Auth.Apply(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return r.Client.Do(req)
+}
+
+// fetchToken follows the bearer token flow of the distribution API. The
+// registry answers with a challenge that points to the token service, which
+// hands out a token for the configured credentials, or an anonymous token for
+// public repositories.
+func (r *OCIRegistry) fetchToken(ctx context.Context, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid realm in authentication challenge %q", challenge)
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	realm.RawQuery = query.Encode()
+
+	resp, err := r.get(ctx, realm.String(), "", realm.Host)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching token from %s", resp.StatusCode, realm.Host)
+	}
+
+	var token ociToken
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+
+	return "", errors.New("token service did not return a token")
+}
+
+// parseChallenge parses a WWW-Authenticate header such as
+// Bearer realm="https://auth.example.com/token",service="example.com"
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := make(map[string]string)
+
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+
+	return scheme, params
+}
+
+// nextPage resolves the URL of the next page from a Link header such as
+// </v2/chart/tags/list?last=1.0.0&n=100>; rel="next"
+func nextPage(current, link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+
+	target, params, _ := strings.Cut(link, ";")
+	if !strings.Contains(params, `rel="next"`) {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := base.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+	if err != nil {
+		return "", err
+	}
+
+	return next.String(), nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry simulates an OCI registry that serves the tags of a single
+// chart in pages of two and, when a token is set, requires bearer tokens.
+func fakeRegistry(t *testing.T, token string, tags []string) *httptest.Server {
+	mux := http.NewServeMux()
+	var server *httptest.Server
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); ok && (username != "user" || password != "pass") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		Equals(r.URL.Query().Get("service"), "fake-registry", t)
+		Equals(r.URL.Query().Get("scope"), "repository:charts/test:pull", t)
+		_ = json.NewEncoder(w).Encode(ociToken{Token: token})
+	})
+
+	mux.HandleFunc("/v2/charts/test/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="fake-registry",scope="repository:charts/test:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		start := 0
+		if last := r.URL.Query().Get("last"); last != "" {
+			for i, tag := range tags {
+				if tag == last {
+					start = i + 1
+				}
+			}
+		}
+
+		end := start + 2
+		if end < len(tags) {
+			w.Header().Set("Link", `</v2/charts/test/tags/list?last=`+tags[end-1]+`>; rel="next"`)
+		} else {
+			end = len(tags)
+		}
+
+		_ = json.NewEncoder(w).Encode(ociTagList{Name: "charts/test", Tags: tags[start:end]})
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func ociRepositoryFor(server *httptest.Server) Repository {
+	return Repository{
+		URL:       "oci://" + strings.TrimPrefix(server.URL, "http://") + "/charts",
+		Charts:    []Chart{{Name: "test"}},
+		PlainHTTP: true,
+	}
+}
+
+func TestIsOCIRepository(t *testing.T) {
+	Equals(IsOCIRepository("oci://registry-1.docker.io/bitnamicharts"), true, t)
+	Equals(IsOCIRepository("https://charts.bitnami.com/bitnami/index.yaml"), false, t)
+}
+
+func TestNewOCIRegistry(t *testing.T) {
+	registry, err := NewOCIRegistry(Repository{URL: "oci://registry-1.docker.io/bitnamicharts/"})
+
+	Equals(err, nil, t)
+	Equals(registry.Host, "registry-1.docker.io", t)
+	Equals(registry.Namespace, "bitnamicharts", t)
+	Equals(registry.PlainHTTP, false, t)
+}
+
+func TestNewOCIRegistry_NoHost(t *testing.T) {
+	_, err := NewOCIRegistry(Repository{URL: "oci://"})
+
+	Equals(err != nil, true, t)
+}
+
+func TestOCIRegistry_Tags_Anonymous(t *testing.T) {
+	server := fakeRegistry(t, "", []string{"1.0.0", "1.0.1", "1.1.0"})
+	defer server.Close()
+
+	registry, _ := NewOCIRegistry(ociRepositoryFor(server))
+	tags, err := registry.Tags(context.Background(), "test")
+
+	Equals(err, nil, t)
+	MapsEqual(tags, []string{"1.0.0", "1.0.1", "1.1.0"}, t)
+}
+
+func TestOCIRegistry_Tags_BearerToken(t *testing.T) {
+	server := fakeRegistry(t, "s3cr3t", []string{"1.0.0", "1.0.1", "1.1.0", "2.0.0", "2.0.1"})
+	defer server.Close()
+
+	registry, _ := NewOCIRegistry(ociRepositoryFor(server))
+	tags, err := registry.Tags(context.Background(), "test")
+
+	Equals(err, nil, t)
+	Equals(len(tags), 5, t)
+}
+
+func TestOCIRegistry_Tags_Credentials(t *testing.T) {
+	server := fakeRegistry(t, "s3cr3t", []string{"1.0.0"})
+	defer server.Close()
+
+	repo := ociRepositoryFor(server)
+	repo.Auth = &RepositoryAuth{Username: "user", Password: &Secret{Value: "wrong"}}
+	registry, _ := NewOCIRegistry(repo)
+	_, err := registry.Tags(context.Background(), "test")
+	Equals(err != nil, true, t)
+
+	repo.Auth.Password = &Secret{Value: "pass"}
+	registry, _ = NewOCIRegistry(repo)
+	tags, err := registry.Tags(context.Background(), "test")
+	Equals(err, nil, t)
+	MapsEqual(tags, []string{"1.0.0"}, t)
+}
+
+func TestOCIRegistry_Tags_StaticToken(t *testing.T) {
+	server := fakeRegistry(t, "s3cr3t", []string{"1.0.0"})
+	defer server.Close()
+
+	repo := ociRepositoryFor(server)
+	repo.Auth = &RepositoryAuth{Token: &Secret{Value: "s3cr3t"}}
+	registry, _ := NewOCIRegistry(repo)
+	tags, err := registry.Tags(context.Background(), "test")
+
+	Equals(err, nil, t)
+	MapsEqual(tags, []string{"1.0.0"}, t)
+}
+
+func TestOCIRegistry_Tags_CredentialsStayOnRegistry(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(r.Header.Get("X-Api-Key"), "", t)
+		Equals(r.Header.Get("Authorization"), "", t)
+		_ = json.NewEncoder(w).Encode(ociTagList{Name: "charts/test", Tags: []string{"2.0.0"}})
+	}))
+	defer other.Close()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(r.Header.Get("X-Api-Key"), "key", t)
+		w.Header().Set("Link", "<"+other.URL+`/v2/charts/test/tags/list?last=1.0.0>; rel="next"`)
+		_ = json.NewEncoder(w).Encode(ociTagList{Name: "charts/test", Tags: []string{"1.0.0"}})
+	}))
+	defer registry.Close()
+
+	repo := ociRepositoryFor(registry)
+	repo.Auth = &RepositoryAuth{Token: &Secret{Value: "s3cr3t"}, Headers: map[string]Secret{"X-Api-Key": {Value: "key"}}}
+	oci, _ := NewOCIRegistry(repo)
+	tags, err := oci.Tags(context.Background(), "test")
+
+	Equals(err, nil, t)
+	MapsEqual(tags, []string{"1.0.0", "2.0.0"}, t)
+}
+
+func TestOCIRegistry_Tags_UnknownChart(t *testing.T) {
+	server := fakeRegistry(t, "", []string{"1.0.0"})
+	defer server.Close()
+
+	registry, _ := NewOCIRegistry(ociRepositoryFor(server))
+	_, err := registry.Tags(context.Background(), "unknown")
+
+	Equals(err != nil, true, t)
+}
+
+func TestOCIRegistry_FetchRepositoryContents(t *testing.T) {
+	server := fakeRegistry(t, "s3cr3t", []string{"1.0.0", "latest", "1.1.0_build.1"})
+	defer server.Close()
+
+	repo := ociRepositoryFor(server)
+	registry, _ := NewOCIRegistry(repo)
+	contents, err := registry.FetchRepositoryContents(context.Background(), repo)
+
+	Equals(err, nil, t)
+	Equals(contents.URL, repo.URL, t)
+	Equals(len(contents.Versions["test"]), 2, t)
+	Equals(contents.Versions["test"][1].String(), "1.1.0+build.1", t)
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:bitnamicharts/nginx:pull"`)
+
+	Equals(scheme, "Bearer", t)
+	MapsEqual(params, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:bitnamicharts/nginx:pull",
+	}, t)
+}
+
+func TestNextPage(t *testing.T) {
+	next, err := nextPage("https://example.com/v2/chart/tags/list", `</v2/chart/tags/list?last=1.0.0&n=100>; rel="next"`)
+
+	Equals(err, nil, t)
+	Equals(next, "https://example.com/v2/chart/tags/list?last=1.0.0&n=100", t)
+
+	next, _ = nextPage("https://example.com/v2/chart/tags/list", "")
+	Equals(next, "", t)
+}
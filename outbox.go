@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// outboxBucket holds the notifications that still have to be delivered, keyed
+// by notifier name and notification ID. deadLettersBucket holds the ones that
+// were given up on, so they can be inspected.
+const outboxBucket = "outbox"
+const deadLettersBucket = "dead_letters"
+
+const (
+	outboxInitialBackoff = 10 * time.Second
+	outboxMaxBackoff     = time.Hour
+	// outboxMaxAttempts is the number of attempts after which a notification
+	// is given up on, which is about two days with the maximum backoff.
+	outboxMaxAttempts = 50
+)
+
+// RetryAfterError is returned by notifiers that were asked to come back later,
+// such as on a 429 Too Many Requests.
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err, e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError is returned by notifiers for deliveries that will never
+// succeed, such as a message the destination rejects as invalid.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// statusError returns err for an unsuccessful response, as a PermanentError
+// for client errors that are not resolved by trying again.
+func statusError(status int, err error) error {
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return &PermanentError{Err: err}
+	}
+
+	return err
+}
+
+// ReportLimiter is implemented by notifiers that can only send a limited
+// number of reports in a single message. The outbox splits larger
+// notifications, so every part is delivered and retried on its own.
+type ReportLimiter interface {
+	MaxReports() int
+}
+
+// parseRetryAfter parses a Retry-After header, which contains either a number
+// of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// OutboxEntry is a notification that still has to be delivered to a notifier.
+type OutboxEntry struct {
+	Notifier     string       `json:"notifier"`
+	Notification Notification `json:"notification"`
+	Created      time.Time    `json:"created"`
+	// Part orders the parts of a notification that was split.
+	Part        int       `json:"part,omitempty"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Outbox delivers notifications reliably. Notifications are stored before
+// they are delivered and are only removed once the notifier succeeded, so
+// neither outages of the destination nor restarts lose them. Failed
+// deliveries are retried with exponential backoff, until the failure is
+// permanent or the attempts run out, after which the notification is moved to
+// the dead letters. Notifiers with a delivery window only get notifications
+// whilst the window is open.
+type Outbox struct {
+	store     Store
+	notifiers map[string]Notifier
+	windows   map[string]*DeliveryWindow
+	now       func() time.Time
+	wake      chan struct{}
+	mutex     sync.Mutex
+}
+
+// NewOutbox returns an outbox for the notifiers. The windows limit the
+// deliveries to the notifiers they are named after, the other notifiers are
+// delivered to at any time.
+func NewOutbox(store Store, notifiers []Notifier, windows map[string]*DeliveryWindow) *Outbox {
+	byName := make(map[string]Notifier, len(notifiers))
+	for _, n := range notifiers {
+		byName[n.Name()] = n
+	}
+
+	return &Outbox{
+		store:     store,
+		notifiers: byName,
+		windows:   windows,
+		now:       time.Now,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Enqueue stores the notification for every notifier.
+func (o *Outbox) Enqueue(notification Notification) error {
+	for name := range o.notifiers {
+		err := o.EnqueueFor(name, notification)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnqueueFor stores the notification for a single notifier and wakes up Run.
+func (o *Outbox) EnqueueFor(name string, notification Notification) error {
+	now := o.now()
+	for i, part := range o.split(name, notification) {
+		err := o.store.Put(outboxBucket, name+"/"+part.ID(), OutboxEntry{
+			Notifier:     name,
+			Notification: part,
+			Created:      now,
+			Part:         i,
+			NextAttempt:  o.windows[name].NextOpen(now),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// split splits the reports of the notification in parts the notifier can send
+// in a single message.
+func (o *Outbox) split(name string, notification Notification) []Notification {
+	limiter, ok := o.notifiers[name].(ReportLimiter)
+	if !ok || notification.Text != "" || len(notification.Reports) <= limiter.MaxReports() {
+		return []Notification{notification}
+	}
+
+	parts := make([]Notification, 0)
+	reports := notification.Reports
+	for len(reports) > 0 {
+		size := limiter.MaxReports()
+		if size > len(reports) {
+			size = len(reports)
+		}
+
+		parts = append(parts, Notification{Reports: reports[:size]})
+		reports = reports[size:]
+	}
+
+	return parts
+}
+
+// Run delivers notifications until the context is cancelled.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		wait := o.Deliver()
+		if wait <= 0 {
+			wait = outboxMaxBackoff
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Deliver sends every notification that is due, oldest first, and returns the
+// time until the next attempt, or 0 when the outbox is empty. Once a delivery
+// to a notifier fails, its later notifications wait as well, so they are
+// delivered in order.
+func (o *Outbox) Deliver() time.Duration {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	keys, err := o.store.Keys(outboxBucket)
+	if err != nil {
+		log.Println("Could not list the outbox", err)
+		return outboxInitialBackoff
+	}
+
+	entries := make(map[string]OutboxEntry, len(keys))
+	for _, key := range keys {
+		var entry OutboxEntry
+		found, err := o.store.Get(outboxBucket, key, &entry)
+		if err != nil {
+			log.Println("Could not load", key, "from the outbox", err)
+			continue
+		}
+		if found {
+			entries[key] = entry
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := entries[keys[i]], entries[keys[j]]
+		if a.Created.Equal(b.Created) {
+			return a.Part < b.Part
+		}
+		return a.Created.Before(b.Created)
+	})
+
+	var next time.Time
+	blocked := make(map[string]bool)
+	for _, key := range keys {
+		entry, ok := entries[key]
+		if !ok {
+			continue
+		}
+
+		notifier, ok := o.notifiers[entry.Notifier]
+		if !ok {
+			log.Println("Dropping notification for notifier", entry.Notifier, "which is no longer configured")
+			o.remove(key)
+			continue
+		}
+
+		now := o.now()
+		if !entry.NextAttempt.After(now) {
+			entry.NextAttempt = o.windows[entry.Notifier].NextOpen(now)
+		}
+
+		if blocked[entry.Notifier] || entry.NextAttempt.After(now) {
+			blocked[entry.Notifier] = true
+			if next.IsZero() || entry.NextAttempt.Before(next) {
+				next = entry.NextAttempt
+			}
+			continue
+		}
+
+		err := notifier.Notify(entry.Notification)
+		metrics.ObserveNotification(entry.Notifier, err)
+		if err == nil {
+			o.remove(key)
+			continue
+		}
+
+		entry.Attempts++
+		entry.LastError = err.Error()
+		log.Println("Could not deliver notification with notifier", entry.Notifier, "attempt", entry.Attempts, err)
+
+		var permanent *PermanentError
+		if errors.As(err, &permanent) || entry.Attempts >= outboxMaxAttempts {
+			o.bury(key, entry)
+			continue
+		}
+
+		entry.NextAttempt = now.Add(outboxBackoff(entry.Attempts, err))
+
+		err = o.store.Put(outboxBucket, key, entry)
+		if err != nil {
+			log.Println("Could not update", key, "in the outbox", err)
+		}
+
+		blocked[entry.Notifier] = true
+		if next.IsZero() || entry.NextAttempt.Before(next) {
+			next = entry.NextAttempt
+		}
+	}
+
+	if next.IsZero() {
+		return 0
+	}
+
+	wait := next.Sub(o.now())
+	if wait <= 0 {
+		wait = time.Millisecond
+	}
+
+	return wait
+}
+
+// Pending returns the number of notifications that still have to be delivered.
+func (o *Outbox) Pending() int {
+	keys, err := o.store.Keys(outboxBucket)
+	if err != nil {
+		return 0
+	}
+
+	return len(keys)
+}
+
+// DeadLetters returns the number of notifications that were given up on.
+func (o *Outbox) DeadLetters() int {
+	keys, err := o.store.Keys(deadLettersBucket)
+	if err != nil {
+		return 0
+	}
+
+	return len(keys)
+}
+
+// bury moves the entry to the dead letters, so the later notifications for
+// the notifier are no longer held up by it.
+func (o *Outbox) bury(key string, entry OutboxEntry) {
+	log.Println("Giving up on notification for notifier", entry.Notifier, "after", entry.Attempts, "attempts, it is kept in the dead letters")
+	err := o.store.Put(deadLettersBucket, key, entry)
+	if err != nil {
+		log.Println("Could not add", key, "to the dead letters", err)
+	}
+	o.remove(key)
+}
+
+func (o *Outbox) remove(key string) {
+	err := o.store.Delete(outboxBucket, key)
+	if err != nil {
+		log.Println("Could not remove", key, "from the outbox", err)
+	}
+}
+
+// outboxBackoff doubles the backoff for every attempt, unless the notifier
+// said when to retry.
+func outboxBackoff(attempts int, err error) time.Duration {
+	var retryAfter *RetryAfterError
+	if errors.As(err, &retryAfter) && retryAfter.After > 0 {
+		return retryAfter.After
+	}
+
+	backoff := outboxInitialBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+
+	return backoff
+}
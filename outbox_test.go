@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testOutbox(store Store, notifiers ...Notifier) (*Outbox, *time.Time) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	outbox := NewOutbox(store, notifiers, nil)
+	outbox.now = func() time.Time { return now }
+
+	return outbox, &now
+}
+
+func TestOutbox_Deliver(t *testing.T) {
+	first := &fakeNotifier{name: "first"}
+	second := &fakeNotifier{name: "second"}
+	outbox, _ := testOutbox(NewMemoryStore(), first, second)
+
+	Equals(outbox.Enqueue(Notification{Reports: []Report{testReport()}}), nil, t)
+	Equals(outbox.Pending(), 2, t)
+
+	Equals(outbox.Deliver(), time.Duration(0), t)
+	Equals(outbox.Pending(), 0, t)
+	Equals(len(first.notifications), 1, t)
+	Equals(len(second.notifications), 1, t)
+	Equals(first.notifications[0].Reports[0].NewVersion.String(), "1.1.0", t)
+}
+
+func TestOutbox_Deliver_RetriesWithBackoff(t *testing.T) {
+	failing := &fakeNotifier{name: "failing", err: errors.New("unavailable")}
+	outbox, now := testOutbox(NewMemoryStore(), failing)
+
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+	Equals(outbox.Deliver(), outboxInitialBackoff, t)
+	Equals(outbox.Deliver(), outboxInitialBackoff, t)
+	Equals(len(failing.notifications), 1, t)
+
+	*now = now.Add(outboxInitialBackoff)
+	Equals(outbox.Deliver(), 2*outboxInitialBackoff, t)
+	Equals(len(failing.notifications), 2, t)
+
+	failing.err = nil
+	*now = now.Add(2 * outboxInitialBackoff)
+	Equals(outbox.Deliver(), time.Duration(0), t)
+	Equals(len(failing.notifications), 3, t)
+	Equals(outbox.Pending(), 0, t)
+}
+
+func TestOutbox_Deliver_HonoursRetryAfter(t *testing.T) {
+	limited := &fakeNotifier{name: "limited", err: &RetryAfterError{After: 42 * time.Second, Err: errors.New("429")}}
+	outbox, _ := testOutbox(NewMemoryStore(), limited)
+
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+
+	Equals(outbox.Deliver(), 42*time.Second, t)
+}
+
+func TestOutbox_Deliver_KeepsOrder(t *testing.T) {
+	failing := &fakeNotifier{name: "failing", err: errors.New("unavailable")}
+	working := &fakeNotifier{name: "working"}
+	outbox, now := testOutbox(NewMemoryStore(), failing, working)
+
+	_ = outbox.Enqueue(Notification{Text: "first"})
+	*now = now.Add(time.Second)
+	_ = outbox.Enqueue(Notification{Text: "second"})
+	outbox.Deliver()
+
+	Equals(len(failing.notifications), 1, t)
+	Equals(failing.notifications[0].Text, "first", t)
+	Equals(len(working.notifications), 2, t)
+	Equals(outbox.Pending(), 2, t)
+}
+
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, _ := OpenFileStore(path)
+	failing := &fakeNotifier{name: "team", err: errors.New("unavailable")}
+	outbox, _ := testOutbox(store, failing)
+	_ = outbox.Enqueue(Notification{Reports: []Report{testReport()}})
+	outbox.Deliver()
+	store.Close()
+
+	reopened, _ := OpenFileStore(path)
+	defer reopened.Close()
+	working := &fakeNotifier{name: "team"}
+	outbox, now := testOutbox(reopened, working)
+	*now = now.Add(outboxMaxBackoff)
+
+	Equals(outbox.Deliver(), time.Duration(0), t)
+	Equals(len(working.notifications), 1, t)
+	Equals(working.notifications[0].ID(), Notification{Reports: []Report{testReport()}}.ID(), t)
+}
+
+func TestOutbox_Deliver_DropsUnknownNotifiers(t *testing.T) {
+	store := NewMemoryStore()
+	outbox, _ := testOutbox(store, &fakeNotifier{name: "removed"})
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+
+	outbox, _ = testOutbox(store)
+	outbox.Deliver()
+
+	Equals(outbox.Pending(), 0, t)
+}
+
+func TestOutbox_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	delivered := make(chan Notification, 1)
+	outbox := NewOutbox(NewMemoryStore(), []Notifier{&channelNotifier{name: "team", ch: delivered}}, nil)
+	go outbox.Run(ctx)
+
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+
+	select {
+	case n := <-delivered:
+		Equals(n.Text, "hello", t)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification was not delivered")
+	}
+}
+
+type channelNotifier struct {
+	name string
+	ch   chan Notification
+}
+
+func (c *channelNotifier) Name() string {
+	return c.name
+}
+
+func (c *channelNotifier) Notify(notification Notification) error {
+	c.ch <- notification
+	return nil
+}
+
+func TestOutbox_Deliver_PermanentErrorUnblocks(t *testing.T) {
+	rejecting := &fakeNotifier{name: "team", err: &PermanentError{Err: errors.New("invalid_blocks")}}
+	outbox, now := testOutbox(NewMemoryStore(), rejecting)
+
+	_ = outbox.Enqueue(Notification{Text: "first"})
+	*now = now.Add(time.Second)
+	_ = outbox.Enqueue(Notification{Text: "second"})
+
+	Equals(outbox.Deliver(), time.Duration(0), t)
+	Equals(len(rejecting.notifications), 2, t)
+	Equals(outbox.Pending(), 0, t)
+	Equals(outbox.DeadLetters(), 2, t)
+}
+
+func TestOutbox_Deliver_GivesUpAfterMaxAttempts(t *testing.T) {
+	failing := &fakeNotifier{name: "team", err: errors.New("unavailable")}
+	outbox, now := testOutbox(NewMemoryStore(), failing)
+
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+	for i := 0; i < outboxMaxAttempts; i++ {
+		outbox.Deliver()
+		*now = now.Add(outboxMaxBackoff)
+	}
+
+	Equals(len(failing.notifications), outboxMaxAttempts, t)
+	Equals(outbox.Pending(), 0, t)
+	Equals(outbox.DeadLetters(), 1, t)
+}
+
+type limitedNotifier struct {
+	fakeNotifier
+	max int
+}
+
+func (l *limitedNotifier) MaxReports() int {
+	return l.max
+}
+
+func TestOutbox_EnqueueFor_Splits(t *testing.T) {
+	limited := &limitedNotifier{fakeNotifier: fakeNotifier{name: "team"}, max: 2}
+	outbox, _ := testOutbox(NewMemoryStore(), limited)
+	reports := make([]Report, 5)
+	for i := range reports {
+		reports[i] = testReport()
+		reports[i].Chart = ChartName(fmt.Sprintf("chart-%d", i))
+	}
+
+	_ = outbox.Enqueue(Notification{Reports: reports})
+	Equals(outbox.Pending(), 3, t)
+
+	outbox.Deliver()
+	Equals(len(limited.notifications), 3, t)
+	Equals(len(limited.notifications[0].Reports), 2, t)
+	Equals(limited.notifications[0].Reports[0].Chart, ChartName("chart-0"), t)
+	Equals(len(limited.notifications[2].Reports), 1, t)
+	Equals(limited.notifications[2].Reports[0].Chart, ChartName("chart-4"), t)
+}
+
+func TestOutbox_Deliver_SplitDiscordMessagesAreNotResent(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	outbox, now := testOutbox(NewMemoryStore(), NewDiscordNotifier("community", server.URL))
+	reports := make([]Report, maxDiscordEmbeds+1)
+	for i := range reports {
+		reports[i] = testReport()
+		reports[i].Chart = ChartName(fmt.Sprintf("chart-%d", i))
+	}
+	_ = outbox.Enqueue(Notification{Reports: reports})
+
+	outbox.Deliver()
+	Equals(outbox.Pending(), 1, t)
+
+	*now = now.Add(outboxInitialBackoff)
+	outbox.Deliver()
+	Equals(requests, 3, t)
+	Equals(outbox.Pending(), 0, t)
+}
+
+func TestStatusError(t *testing.T) {
+	var permanent *PermanentError
+	Equals(errors.As(statusError(400, errors.New("bad request")), &permanent), true, t)
+	Equals(errors.As(statusError(404, errors.New("not found")), &permanent), true, t)
+	Equals(errors.As(statusError(429, errors.New("too many requests")), &permanent), false, t)
+	Equals(errors.As(statusError(503, errors.New("unavailable")), &permanent), false, t)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	Equals(outboxBackoff(1, errors.New("failed")), outboxInitialBackoff, t)
+	Equals(outboxBackoff(3, errors.New("failed")), 4*outboxInitialBackoff, t)
+	Equals(outboxBackoff(100, errors.New("failed")), outboxMaxBackoff, t)
+	Equals(outboxBackoff(3, &RetryAfterError{After: time.Minute}), time.Minute, t)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	Equals(parseRetryAfter("120", now), 2*time.Minute, t)
+	Equals(parseRetryAfter("Sun, 01 Jan 2023 00:00:30 GMT", now), 30*time.Second, t)
+	Equals(parseRetryAfter("", now), time.Duration(0), t)
+}
+
+func TestDoRequest_TooManyRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier("team", server.URL).Notify(Notification{Text: "hello"})
+
+	var retryAfter *RetryAfterError
+	Equals(errors.As(err, &retryAfter), true, t)
+	Equals(retryAfter.After, 30*time.Second, t)
+}
+
+func TestOutbox_Deliver_DeliveryWindow(t *testing.T) {
+	daytime := &fakeNotifier{name: "daytime"}
+	always := &fakeNotifier{name: "always"}
+	outbox, now := testOutbox(NewMemoryStore(), daytime, always)
+	window, _ := ParseDeliveryWindow("every day 08:00-20:00 UTC")
+	outbox.windows = map[string]*DeliveryWindow{"daytime": window}
+
+	// It is midnight, so the daytime notifier has to wait 8 hours.
+	_ = outbox.Enqueue(Notification{Reports: []Report{testReport()}})
+	Equals(outbox.Deliver(), 8*time.Hour, t)
+	Equals(len(daytime.notifications), 0, t)
+	Equals(len(always.notifications), 1, t)
+
+	*now = now.Add(8 * time.Hour)
+	Equals(outbox.Deliver(), time.Duration(0), t)
+	Equals(len(daytime.notifications), 1, t)
+}
+
+func TestOutbox_Deliver_RetryOutsideDeliveryWindow(t *testing.T) {
+	daytime := &fakeNotifier{name: "daytime", err: errors.New("unavailable")}
+	outbox, now := testOutbox(NewMemoryStore(), daytime)
+	window, _ := ParseDeliveryWindow("every day 00:00-00:05 UTC")
+	outbox.windows = map[string]*DeliveryWindow{"daytime": window}
+
+	_ = outbox.Enqueue(Notification{Text: "hello"})
+	outbox.Deliver()
+	Equals(len(daytime.notifications), 1, t)
+
+	// The retry is due after the window closed, so it waits for the next one.
+	daytime.err = nil
+	*now = now.Add(10 * time.Minute)
+	Equals(outbox.Deliver(), 24*time.Hour-10*time.Minute, t)
+	Equals(len(daytime.notifications), 1, t)
+}
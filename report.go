@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+type Report struct {
+	Repository string
+	// RepositoryName is the configured name of the repository, or its URL when
+	// it does not have a name.
+	RepositoryName  string
+	Chart           ChartName
+	PreviousVersion *semver.Version
+	NewVersion      *semver.Version
+	// NewVersions contains every release newer than PreviousVersion in
+	// ascending order, so NewVersion is always the last one.
+	NewVersions semver.Collection
+	Dependees   []string
+	// Preview indicates the report is about prereleases that are tracked
+	// separately from stable releases.
+	Preview bool
+	// Metadata is the index entry of NewVersion. OCI registries do not provide
+	// metadata, so only its version is known for their charts.
+	Metadata Entry
+	// DetectedAt is the moment the update was found.
+	DetectedAt time.Time
+}
+
+// ID identifies the update, the same update always has the same ID.
+func (r Report) ID() string {
+	return hashOf(r.Repository, string(r.Chart), r.PreviousVersion.String(), r.NewVersion.String(), strconv.FormatBool(r.Preview))
+}
+
+// Bump returns the kind of version bump from PreviousVersion to NewVersion.
+func (r Report) Bump() Bump {
+	return BumpBetween(r.PreviousVersion, r.NewVersion)
+}
+
+// Message returns the human-readable description of the report.
+func (r Report) Message() string {
+	text := fmt.Sprintf("Chart *%s* in repo %s updated to version *%s*", r.Chart, r.Repository, r.NewVersion)
+	if r.Preview {
+		text = fmt.Sprintf("Chart *%s* in repo %s has a new preview version *%s*", r.Chart, r.Repository, r.NewVersion)
+	}
+	if len(r.NewVersions) > 1 {
+		text += fmt.Sprintf("\nReleased since %s: %s", r.PreviousVersion, strings.Join(versionStrings(r.NewVersions), ", "))
+	}
+	if len(r.Dependees) > 0 {
+		text = fmt.Sprintln(text, "\nYou might want to check:", strings.Join(r.Dependees, ", "))
+	}
+
+	return text
+}
+
+func versionStrings(versions semver.Collection) []string {
+	s := make([]string, len(versions))
+	for i, v := range versions {
+		s[i] = v.String()
+	}
+
+	return s
+}
@@ -9,14 +9,22 @@ import (
 type ChartName string
 
 type RepositoryContents struct {
-	Entries   map[ChartName][]Entry `yaml:"entries"`
-	Versions  map[ChartName]semver.Collection
+	Entries  map[ChartName][]Entry `yaml:"entries"`
+	Versions map[ChartName]semver.Collection
+	// Previews contains the prereleases of charts that track them separately.
+	Previews  map[ChartName]semver.Collection
 	Generated string `yaml:"generated"`
 	URL       string
 }
 
+// Entry is a chart version in the index. Apart from the version only the
+// metadata that is useful in notifications is kept.
 type Entry struct {
-	Version string `yaml:"version"`
+	Version     string   `yaml:"version" json:"version"`
+	AppVersion  string   `yaml:"appVersion,omitempty" json:"appVersion,omitempty"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Home        string   `yaml:"home,omitempty" json:"home,omitempty"`
+	Sources     []string `yaml:"sources,omitempty" json:"sources,omitempty"`
 }
 
 func (rc *RepositoryContents) FilterCharts(chartsToKeep []Chart) {
@@ -32,6 +40,39 @@ func (rc *RepositoryContents) FilterCharts(chartsToKeep []Chart) {
 	rc.Entries = filtered
 }
 
+// FilterVersions drops the versions that do not satisfy the constraints of the
+// charts and handles prereleases according to their prerelease policy.
+func (rc *RepositoryContents) FilterVersions(charts []Chart) {
+	for _, chart := range charts {
+		versions, ok := rc.Versions[chart.Name]
+		if !ok {
+			continue
+		}
+
+		allowed := make(semver.Collection, 0, len(versions))
+		previews := make(semver.Collection, 0)
+		for _, v := range versions {
+			if !chart.Allows(v) {
+				continue
+			}
+
+			if v.Prerelease() == "" || chart.Prereleases == PrereleasesInclude {
+				allowed = append(allowed, v)
+			} else if chart.Prereleases == PrereleasesSeparate {
+				previews = append(previews, v)
+			}
+		}
+		rc.Versions[chart.Name] = allowed
+
+		if chart.Prereleases == PrereleasesSeparate {
+			if rc.Previews == nil {
+				rc.Previews = make(map[ChartName]semver.Collection)
+			}
+			rc.Previews[chart.Name] = previews
+		}
+	}
+}
+
 func (rc *RepositoryContents) EntriesToVersions() {
 	if rc.Versions == nil {
 		rc.Versions = make(map[ChartName]semver.Collection)
@@ -52,3 +93,29 @@ func (rc *RepositoryContents) EntriesToVersions() {
 		rc.Versions[k] = versions
 	}
 }
+
+// Entry returns the entry of the given version of a chart.
+func (rc *RepositoryContents) Entry(chart ChartName, version *semver.Version) (Entry, bool) {
+	for _, e := range rc.Entries[chart] {
+		v, err := semver.NewVersion(e.Version)
+		if err == nil && v.Equal(version) {
+			return e, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// VersionsNewerThan returns every version that is newer than the given
+// version, in ascending order.
+func VersionsNewerThan(versions semver.Collection, version *semver.Version) semver.Collection {
+	newer := make(semver.Collection, 0)
+	for _, v := range versions {
+		if v.GreaterThan(version) {
+			newer = append(newer, v)
+		}
+	}
+
+	sort.Sort(newer)
+	return newer
+}
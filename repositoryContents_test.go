@@ -24,7 +24,7 @@ func TestRepositoryContents_FilterCharts(t *testing.T) {
 	rc.FilterCharts([]Chart{{Name: "keep"}})
 
 	Equals(len(rc.Entries), 1, t)
-	Equals(rc.Entries["keep"][0], Entry{Version: "1.2.3"}, t)
+	MapsEqual(rc.Entries["keep"][0], Entry{Version: "1.2.3"}, t)
 }
 
 func TestRepositoryContents_FilterCharts_NonExistingChart(t *testing.T) {
@@ -114,3 +114,96 @@ func TestRepositoryContents_EntriesToVersions_SortsVersions(t *testing.T) {
 	}
 	MapsEqual(rc.Versions, expected, t)
 }
+
+func TestRepositoryContents_VersionsNewerThan(t *testing.T) {
+	rc := RepositoryContents{
+		Entries: map[ChartName][]Entry{
+			"chart": {
+				Entry{Version: "4.6.0"},
+				Entry{Version: "4.5.2"},
+				Entry{Version: "4.5.4"},
+				Entry{Version: "4.5.3"},
+			},
+		},
+	}
+	rc.EntriesToVersions()
+
+	result := VersionsNewerThan(rc.Versions["chart"], semver.MustParse("4.5.2"))
+
+	expected := semver.Collection{semver.MustParse("4.5.3"), semver.MustParse("4.5.4"), semver.MustParse("4.6.0")}
+	MapsEqual(result, expected, t)
+}
+
+func TestRepositoryContents_VersionsNewerThan_NothingNewer(t *testing.T) {
+	rc := RepositoryContents{
+		Entries: map[ChartName][]Entry{
+			"chart": {Entry{Version: "1.0.0"}},
+		},
+	}
+	rc.EntriesToVersions()
+
+	result := VersionsNewerThan(rc.Versions["chart"], semver.MustParse("1.0.0"))
+
+	Equals(len(result), 0, t)
+}
+
+func TestRepositoryContents_VersionsNewerThan_UnknownChart(t *testing.T) {
+	rc := RepositoryContents{}
+
+	result := VersionsNewerThan(rc.Versions["unknown"], semver.MustParse("1.0.0"))
+
+	Equals(len(result), 0, t)
+}
+
+func TestRepositoryContents_FilterVersions(t *testing.T) {
+	rc := RepositoryContents{
+		Entries: map[ChartName][]Entry{
+			"lts":       {{Version: "1.2.0"}, {Version: "1.2.1"}, {Version: "1.3.0"}, {Version: "2.0.0"}},
+			"unchanged": {{Version: "1.0.0"}, {Version: "2.0.0"}},
+		},
+	}
+	rc.EntriesToVersions()
+
+	rc.FilterVersions([]Chart{{Name: "lts", Constraint: "~1.2"}, {Name: "unchanged"}})
+
+	MapsEqual(rc.Versions["lts"], semver.Collection{semver.MustParse("1.2.0"), semver.MustParse("1.2.1")}, t)
+	Equals(len(rc.Versions["unchanged"]), 2, t)
+}
+
+func TestRepositoryContents_FilterVersions_Prereleases(t *testing.T) {
+	entries := []Entry{{Version: "4.0.0"}, {Version: "5.0.0-rc.1"}, {Version: "5.0.0-rc.2"}}
+	rc := RepositoryContents{
+		Entries: map[ChartName][]Entry{"ignored": entries, "separate": entries, "included": entries},
+	}
+	rc.EntriesToVersions()
+
+	rc.FilterVersions([]Chart{
+		{Name: "ignored", Prereleases: PrereleasesIgnore},
+		{Name: "separate", Prereleases: PrereleasesSeparate},
+		{Name: "included", Prereleases: PrereleasesInclude},
+	})
+
+	MapsEqual(versionStrings(rc.Versions["ignored"]), []string{"4.0.0"}, t)
+	MapsEqual(versionStrings(rc.Versions["separate"]), []string{"4.0.0"}, t)
+	MapsEqual(versionStrings(rc.Previews["separate"]), []string{"5.0.0-rc.1", "5.0.0-rc.2"}, t)
+	MapsEqual(versionStrings(rc.Versions["included"]), []string{"4.0.0", "5.0.0-rc.1", "5.0.0-rc.2"}, t)
+	Equals(len(rc.Previews), 1, t)
+}
+
+func TestRepositoryContents_Entry(t *testing.T) {
+	rc := RepositoryContents{
+		Entries: map[ChartName][]Entry{
+			"chart": {
+				Entry{Version: "v1.0.0", Home: "https://example.com/1"},
+				Entry{Version: "1.1.0", Home: "https://example.com/2"},
+			},
+		},
+	}
+
+	entry, found := rc.Entry("chart", semver.MustParse("1.0.0"))
+	Equals(found, true, t)
+	Equals(entry.Home, "https://example.com/1", t)
+
+	_, found = rc.Entry("chart", semver.MustParse("2.0.0"))
+	Equals(found, false, t)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Route sends the reports that match it to the named notifiers. The
+// repository, chart and dependee are patterns that have to match when they
+// are set: a glob such as "postgres*", or a regular expression between
+// slashes such as "/^(nginx|traefik)-ingress$/". The repository pattern
+// matches both the URL and the name of the repository. A fallback route has no
+// patterns and gets the reports that match no other route.
+type Route struct {
+	Repository string   `json:"repository,omitempty"`
+	Chart      string   `json:"chart,omitempty"`
+	Dependee   string   `json:"dependee,omitempty"`
+	Fallback   bool     `json:"fallback,omitempty"`
+	Notifiers  []string `json:"notifiers"`
+}
+
+func (r Route) Validate() error {
+	if len(r.Notifiers) == 0 {
+		return errors.New("route has no notifiers")
+	}
+
+	if r.Fallback && (r.Repository != "" || r.Chart != "" || r.Dependee != "") {
+		return errors.New("a fallback route should not have a repository, chart or dependee")
+	}
+
+	for _, pattern := range []string{r.Repository, r.Chart, r.Dependee} {
+		_, err := compilePattern(pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pattern matches a string, a nil pattern matches anything.
+type pattern func(string) bool
+
+func compilePattern(p string) (pattern, error) {
+	if p == "" {
+		return nil, nil
+	}
+
+	if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+		re, err := regexp.Compile(p[1 : len(p)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid route pattern %s: %w", p, err)
+		}
+		return re.MatchString, nil
+	}
+
+	_, err := path.Match(p, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid route pattern %s: %w", p, err)
+	}
+
+	return func(s string) bool {
+		matched, _ := path.Match(p, s)
+		return matched
+	}, nil
+}
+
+type compiledRoute struct {
+	repository pattern
+	chart      pattern
+	dependee   pattern
+	notifiers  []string
+}
+
+func (r compiledRoute) matches(report Report) bool {
+	if r.repository != nil && !r.repository(report.Repository) && !r.repository(report.RepositoryName) {
+		return false
+	}
+
+	if r.chart != nil && !r.chart(string(report.Chart)) {
+		return false
+	}
+
+	if r.dependee != nil {
+		for _, d := range report.Dependees {
+			if r.dependee(d) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Router decides which notifiers get a report. A report that matches routes
+// goes to the notifiers of every matching route, a report that matches none
+// goes to the notifiers of the fallback routes, or without those to the
+// notifiers that are not named by any route.
+type Router struct {
+	routes   []compiledRoute
+	fallback map[string]bool
+	unrouted map[string]bool
+}
+
+func NewRouter(routes []Route, notifiers []string) (*Router, error) {
+	router := &Router{fallback: make(map[string]bool), unrouted: make(map[string]bool, len(notifiers))}
+	for _, name := range notifiers {
+		router.unrouted[name] = true
+	}
+
+	for _, r := range routes {
+		err := r.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range r.Notifiers {
+			delete(router.unrouted, name)
+		}
+
+		if r.Fallback {
+			for _, name := range r.Notifiers {
+				router.fallback[name] = true
+			}
+			continue
+		}
+
+		compiled := compiledRoute{notifiers: r.Notifiers}
+		compiled.repository, _ = compilePattern(r.Repository)
+		compiled.chart, _ = compilePattern(r.Chart)
+		compiled.dependee, _ = compilePattern(r.Dependee)
+		router.routes = append(router.routes, compiled)
+	}
+
+	return router, nil
+}
+
+// Routes reports whether the report should be sent to the notifier. A nil
+// router sends every report to every notifier.
+func (r *Router) Routes(report Report, notifier string) bool {
+	if r == nil {
+		return true
+	}
+
+	matched := false
+	for _, route := range r.routes {
+		if !route.matches(report) {
+			continue
+		}
+
+		matched = true
+		for _, name := range route.notifiers {
+			if name == notifier {
+				return true
+			}
+		}
+	}
+
+	if matched {
+		return false
+	}
+	if len(r.fallback) > 0 {
+		return r.fallback[notifier]
+	}
+	return r.unrouted[notifier]
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+)
+
+func testRouter(t *testing.T, routes ...Route) *Router {
+	router, err := NewRouter(routes, []string{"database", "platform", "everything"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return router
+}
+
+func TestRouter_Routes_Chart(t *testing.T) {
+	router := testRouter(t,
+		Route{Chart: "postgres*", Notifiers: []string{"database"}},
+		Route{Chart: "/^(ingress-nginx|traefik)$/", Notifiers: []string{"platform"}},
+	)
+
+	postgres := Report{Chart: "postgresql-ha"}
+	Equals(router.Routes(postgres, "database"), true, t)
+	Equals(router.Routes(postgres, "platform"), false, t)
+	Equals(router.Routes(postgres, "everything"), false, t)
+
+	traefik := Report{Chart: "traefik"}
+	Equals(router.Routes(traefik, "platform"), true, t)
+	Equals(router.Routes(traefik, "database"), false, t)
+}
+
+func TestRouter_Routes_Unmatched(t *testing.T) {
+	router := testRouter(t, Route{Chart: "postgres*", Notifiers: []string{"database"}})
+
+	report := Report{Chart: "redis"}
+	Equals(router.Routes(report, "database"), false, t)
+	Equals(router.Routes(report, "platform"), true, t)
+	Equals(router.Routes(report, "everything"), true, t)
+}
+
+func TestRouter_Routes_RepositoryAndDependee(t *testing.T) {
+	router := testRouter(t,
+		Route{Repository: "bitnami", Dependee: "payments-*", Notifiers: []string{"database", "platform"}},
+	)
+
+	report := Report{Repository: "https://charts.bitnami.com/bitnami", RepositoryName: "bitnami", Chart: "redis", Dependees: []string{"shop", "payments-api"}}
+	Equals(router.Routes(report, "database"), true, t)
+	Equals(router.Routes(report, "platform"), true, t)
+
+	report.Dependees = []string{"shop"}
+	Equals(router.Routes(report, "database"), false, t)
+	Equals(router.Routes(report, "everything"), true, t)
+}
+
+func TestRouter_Routes_Fallback(t *testing.T) {
+	router := testRouter(t,
+		Route{Chart: "postgres*", Notifiers: []string{"database"}},
+		Route{Fallback: true, Notifiers: []string{"platform"}},
+	)
+
+	postgres := Report{Chart: "postgresql"}
+	Equals(router.Routes(postgres, "database"), true, t)
+	Equals(router.Routes(postgres, "platform"), false, t)
+
+	redis := Report{Chart: "redis"}
+	Equals(router.Routes(redis, "platform"), true, t)
+	Equals(router.Routes(redis, "everything"), false, t)
+}
+
+func TestRouter_Routes_Nil(t *testing.T) {
+	var router *Router
+
+	Equals(router.Routes(Report{Chart: "redis"}, "database"), true, t)
+}
+
+func TestRoute_Validate(t *testing.T) {
+	Equals(Route{Chart: "nginx", Notifiers: []string{"platform"}}.Validate(), nil, t)
+	Equals(Route{Chart: "nginx"}.Validate() != nil, true, t)
+	Equals(Route{Chart: "[nginx", Notifiers: []string{"platform"}}.Validate() != nil, true, t)
+	Equals(Route{Chart: "/(nginx/", Notifiers: []string{"platform"}}.Validate() != nil, true, t)
+	Equals(Route{Fallback: true, Notifiers: []string{"platform"}}.Validate(), nil, t)
+	Equals(Route{Fallback: true, Chart: "nginx", Notifiers: []string{"platform"}}.Validate() != nil, true, t)
+}
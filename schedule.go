@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// The timezones of schedules should not depend on the zoneinfo of the
+	// system the monitor runs on.
+	_ "time/tzdata"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Schedule is a recurring moment such as "every monday 09:00
+// Europe/Amsterdam". The days are a comma separated list of weekdays, or day
+// for every day and weekday for monday up to friday. Without a timezone the
+// local time of the monitor is used.
+type Schedule struct {
+	Days     map[time.Weekday]bool
+	Hour     int
+	Minute   int
+	Location *time.Location
+}
+
+func ParseSchedule(s string) (*Schedule, error) {
+	days, clock, location, err := parseRecurring(s, "schedule", "every monday 09:00 Europe/Amsterdam")
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := time.Parse("15:04", clock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: the time should look like 09:00", s)
+	}
+
+	return &Schedule{Days: days, Hour: at.Hour(), Minute: at.Minute(), Location: location}, nil
+}
+
+// parseRecurring splits a recurring moment such as "every monday 09:00
+// Europe/Amsterdam" into its days, its time, which is left to the caller to
+// parse, and its timezone. The kind and example are used in the errors.
+func parseRecurring(s, kind, example string) (map[time.Weekday]bool, string, *time.Location, error) {
+	fields := strings.Fields(s)
+	if len(fields) > 0 && strings.EqualFold(fields[0], "every") {
+		fields = fields[1:]
+	}
+
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, "", nil, fmt.Errorf("invalid %s %q, expected something like %q", kind, s, example)
+	}
+
+	days, err := parseDays(strings.ToLower(fields[0]))
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("invalid %s %q: %w", kind, s, err)
+	}
+
+	location := time.Local
+	if len(fields) == 3 {
+		location, err = time.LoadLocation(fields[2])
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("invalid %s %q: %w", kind, s, err)
+		}
+	}
+
+	return days, fields[1], location, nil
+}
+
+func parseDays(s string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, name := range strings.Split(s, ",") {
+		// Allow plurals such as "mondays", none of the names ends with an s.
+		name = strings.TrimSuffix(name, "s")
+		switch name {
+		case "day":
+			for _, d := range weekdays {
+				days[d] = true
+			}
+		case "weekday":
+			for d := time.Monday; d <= time.Friday; d++ {
+				days[d] = true
+			}
+		default:
+			d, ok := weekdays[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %s", name)
+			}
+			days[d] = true
+		}
+	}
+
+	return days, nil
+}
+
+// Next returns the first moment of the schedule after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	local := t.In(s.Location)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), s.Hour, s.Minute, 0, 0, s.Location)
+		if s.Days[candidate.Weekday()] && candidate.After(t) {
+			return candidate
+		}
+	}
+
+	// Only reachable without days, which ParseSchedule does not allow.
+	return t.AddDate(0, 0, 7)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	schedule, err := ParseSchedule("every Monday 09:00 Europe/Amsterdam")
+
+	Equals(err, nil, t)
+	Equals(len(schedule.Days), 1, t)
+	Equals(schedule.Days[time.Monday], true, t)
+	Equals(schedule.Hour, 9, t)
+	Equals(schedule.Minute, 0, t)
+	Equals(schedule.Location.String(), "Europe/Amsterdam", t)
+}
+
+func TestParseSchedule_Days(t *testing.T) {
+	everyDay, _ := ParseSchedule("every day 18:30")
+	Equals(len(everyDay.Days), 7, t)
+	Equals(everyDay.Location, time.Local, t)
+
+	weekdays, _ := ParseSchedule("weekdays 08:00 UTC")
+	Equals(len(weekdays.Days), 5, t)
+	Equals(weekdays.Days[time.Saturday], false, t)
+
+	list, _ := ParseSchedule("every tuesday,thursdays 12:00")
+	Equals(len(list.Days), 2, t)
+	Equals(list.Days[time.Thursday], true, t)
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, s := range []string{"", "every monday", "every someday 09:00", "every monday 25:00", "every monday 09:00 Mars/Olympus", "every monday 09:00 UTC extra"} {
+		_, err := ParseSchedule(s)
+		Equals(err != nil, true, t)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	schedule, _ := ParseSchedule("every monday 09:00 Europe/Amsterdam")
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+
+	// Sunday 1 January 2023.
+	sunday := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	Equals(schedule.Next(sunday).Equal(time.Date(2023, 1, 2, 9, 0, 0, 0, amsterdam)), true, t)
+
+	// Exactly at the moment of the schedule the next week is returned.
+	monday := time.Date(2023, 1, 2, 9, 0, 0, 0, amsterdam)
+	Equals(schedule.Next(monday).Equal(time.Date(2023, 1, 9, 9, 0, 0, 0, amsterdam)), true, t)
+
+	// Daylight saving time starts on 26 March 2023.
+	Equals(schedule.Next(time.Date(2023, 3, 26, 12, 0, 0, 0, time.UTC)).UTC(), time.Date(2023, 3, 27, 7, 0, 0, 0, time.UTC), t)
+}
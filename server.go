@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// NewServer returns the server that exposes the metrics and the health of the
+// monitor.
+func NewServer(address string, health *Health) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/healthz", health.ServeLiveness)
+	mux.HandleFunc("/readyz", health.ServeReadiness)
+
+	return &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// startServer serves in the background, unless no address is configured.
+func startServer(address string, health *Health) {
+	if address == "" {
+		return
+	}
+
+	server := NewServer(address, health)
+	go func() {
+		log.Println("Listening on", address)
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalln("Could not serve on", address, err)
+		}
+	}()
+}
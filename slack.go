@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxSlackButtons is the number of link buttons that is added to a report,
+// Slack allows at most 25 elements in an actions block but a handful is
+// plenty.
+const maxSlackButtons = 5
+
+// maxSlackReports is the number of reports in a single message. A report takes
+// at most 6 blocks and Slack accepts 50 blocks in a message.
+const maxSlackReports = 8
+
+// Message is the payload of a Slack incoming webhook. Text is shown in
+// notifications and above the attachments, so with attachments it only
+// summarises them.
+type Message struct {
+	Text        string            `json:"text"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+// SlackAttachment holds the Block Kit blocks of a report. Attachments are used
+// because blocks themselves can not be coloured.
+// The fallback holds the full report for clients that do not render blocks.
+type SlackAttachment struct {
+	Color    string       `json:"color"`
+	Fallback string       `json:"fallback,omitempty"`
+	Blocks   []SlackBlock `json:"blocks"`
+}
+
+type SlackBlock struct {
+	Type     string         `json:"type"`
+	Text     *SlackText     `json:"text,omitempty"`
+	Fields   []SlackText    `json:"fields,omitempty"`
+	Elements []SlackElement `json:"elements,omitempty"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackElement struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text,omitempty"`
+	URL  string     `json:"url,omitempty"`
+}
+
+// SlackNotifier posts notifications to a Slack incoming webhook. Reports are
+// rendered as Block Kit messages, unless a template is configured, in which
+// case the rendered template is posted as plain text.
+type SlackNotifier struct {
+	name     string
+	url      string
+	client   *http.Client
+	template *MessageTemplate
+}
+
+func NewSlackNotifier(name, url string) *SlackNotifier {
+	return &SlackNotifier{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: notifierTimeout},
+	}
+}
+
+func (s *SlackNotifier) Name() string {
+	return s.name
+}
+
+func (s *SlackNotifier) MaxReports() int {
+	return maxSlackReports
+}
+
+func (s *SlackNotifier) Notify(notification Notification) error {
+	text, err := s.template.Render(notification)
+	if err != nil {
+		return err
+	}
+
+	message := Message{Text: text}
+	if s.template == nil && notification.Text == "" {
+		for _, r := range notification.Reports {
+			message.Attachments = append(message.Attachments, slackAttachment(r))
+		}
+		message.Text = slackSummary(notification.Reports)
+	}
+
+	return postJSON(s.client, s.url, message)
+}
+
+// slackSummary is the text above the attachments of the reports.
+func slackSummary(reports []Report) string {
+	if len(reports) != 1 {
+		return fmt.Sprintf("%d chart updates", len(reports))
+	}
+
+	r := reports[0]
+	if r.Preview {
+		return fmt.Sprintf("%s has a new preview version %s", slackEscape(string(r.Chart)), slackEscape(r.NewVersion.String()))
+	}
+	return fmt.Sprintf("%s updated to %s", slackEscape(string(r.Chart)), slackEscape(r.NewVersion.String()))
+}
+
+// slackColors and slackEmoji indicate how big an update is at a glance.
+var slackColors = map[Bump]string{
+	BumpMajor: "#e01e5a",
+	BumpMinor: "#ecb22e",
+	BumpPatch: "#2eb67d",
+	BumpNone:  "#868686",
+}
+
+var slackEmoji = map[Bump]string{
+	BumpMajor: ":red_circle:",
+	BumpMinor: ":large_yellow_circle:",
+	BumpPatch: ":large_green_circle:",
+	BumpNone:  ":white_circle:",
+}
+
+func slackAttachment(r Report) SlackAttachment {
+	bump := r.Bump()
+	title := fmt.Sprintf("%s *%s* updated to *%s*", slackEmoji[bump], slackEscape(string(r.Chart)), slackEscape(r.NewVersion.String()))
+	if r.Preview {
+		title = fmt.Sprintf("%s *%s* has a new preview version *%s*", slackEmoji[bump], slackEscape(string(r.Chart)), slackEscape(r.NewVersion.String()))
+	}
+
+	repository := r.RepositoryName
+	if repository == "" {
+		repository = r.Repository
+	}
+
+	blocks := []SlackBlock{
+		{Type: "section", Text: markdown(title)},
+		{Type: "section", Fields: []SlackText{
+			*markdown("*Repository*\n" + slackEscape(repository)),
+			*markdown(fmt.Sprintf("*Version*\n%s → %s", slackEscape(r.PreviousVersion.String()), slackEscape(r.NewVersion.String()))),
+			*markdown("*Bump*\n" + bump.String()),
+		}},
+	}
+
+	if len(r.NewVersions) > 1 {
+		released := fmt.Sprintf("*Released since %s*\n%s", slackEscape(r.PreviousVersion.String()), slackEscape(strings.Join(versionStrings(r.NewVersions), ", ")))
+		blocks = append(blocks, SlackBlock{Type: "section", Text: markdown(released)})
+	}
+
+	if len(r.Dependees) > 0 {
+		blocks = append(blocks, SlackBlock{Type: "section", Text: markdown("*You might want to check*\n" + slackEscape(strings.Join(r.Dependees, ", ")))})
+	}
+
+	if buttons := slackButtons(r.Metadata); len(buttons) > 0 {
+		blocks = append(blocks, SlackBlock{Type: "actions", Elements: buttons})
+	}
+
+	return SlackAttachment{Color: slackColors[bump], Fallback: r.Message(), Blocks: blocks}
+}
+
+// slackButtons links to the home and source URLs of the chart.
+func slackButtons(metadata Entry) []SlackElement {
+	buttons := make([]SlackElement, 0)
+	if metadata.Home != "" {
+		buttons = append(buttons, slackButton("Home", metadata.Home))
+	}
+
+	for i, source := range metadata.Sources {
+		if len(buttons) == maxSlackButtons {
+			break
+		}
+
+		label := "Source"
+		if len(metadata.Sources) > 1 {
+			label = fmt.Sprintf("Source %d", i+1)
+		}
+		buttons = append(buttons, slackButton(label, source))
+	}
+
+	return buttons
+}
+
+func slackButton(label, url string) SlackElement {
+	return SlackElement{Type: "button", Text: &SlackText{Type: "plain_text", Text: label}, URL: url}
+}
+
+func markdown(text string) *SlackText {
+	return &SlackText{Type: "mrkdwn", Text: text}
+}
+
+// slackEscape escapes the characters Slack uses for links and mentions.
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func postJSON(client *http.Client, url string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return doRequest(client, req)
+}
+
+// doRequest sends the request and fails on any response that is not a 2xx.
+func doRequest(client *http.Client, req *http.Request) error {
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		return &RetryAfterError{
+			After: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+			Err:   fmt.Errorf("unexpected response code %d", response.StatusCode),
+		}
+	}
+
+	if response.StatusCode >= 300 {
+		return statusError(response.StatusCode, fmt.Errorf("unexpected response code %d", response.StatusCode))
+	}
+
+	return nil
+}
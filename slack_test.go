@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(r.Header.Get("Content-Type"), "application/json", t)
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier("team", server.URL).Notify(Notification{Text: "hello"})
+
+	Equals(err, nil, t)
+	Equals(received.Text, "hello", t)
+}
+
+func TestSlackNotifier_Notify_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := NewSlackNotifier("team", server.URL).Notify(Notification{Text: "hello"})
+
+	Equals(err.Error(), "unexpected response code 404", t)
+}
+
+func TestSlackNotifier_Notify_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := NewSlackNotifier("team", server.URL).Notify(Notification{Text: "hello"})
+
+	Equals(err != nil, true, t)
+}
+
+func TestSlackNotifier_Notify_Blocks(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	report := testReport()
+	report.RepositoryName = "example"
+	report.Metadata = Entry{Home: "https://example.com/chart", Sources: []string{"https://github.com/example/chart"}}
+	err := NewSlackNotifier("team", server.URL).Notify(Notification{Reports: []Report{report}})
+
+	Equals(err, nil, t)
+	Equals(received.Text, "chart updated to 1.1.0", t)
+	Equals(len(received.Attachments), 1, t)
+
+	attachment := received.Attachments[0]
+	Equals(attachment.Color, slackColors[BumpMinor], t)
+	Equals(attachment.Fallback, report.Message(), t)
+	Equals(attachment.Blocks[0].Text.Text, ":large_yellow_circle: *chart* updated to *1.1.0*", t)
+	Equals(attachment.Blocks[1].Fields[0].Text, "*Repository*\nexample", t)
+	Equals(attachment.Blocks[1].Fields[1].Text, "*Version*\n1.0.0 → 1.1.0", t)
+	Equals(attachment.Blocks[2].Text.Text, "*Released since 1.0.0*\n1.0.1, 1.1.0", t)
+	Equals(attachment.Blocks[3].Text.Text, "*You might want to check*\nExample", t)
+
+	actions := attachment.Blocks[4]
+	Equals(actions.Type, "actions", t)
+	Equals(len(actions.Elements), 2, t)
+	Equals(actions.Elements[0].URL, "https://example.com/chart", t)
+	Equals(actions.Elements[1].Text.Text, "Source", t)
+}
+
+func TestSlackSummary(t *testing.T) {
+	preview := testReport()
+	preview.Preview = true
+
+	Equals(slackSummary([]Report{preview}), "chart has a new preview version 1.1.0", t)
+	Equals(slackSummary([]Report{testReport(), testReport(), testReport()}), "3 chart updates", t)
+}
+
+func TestSlackNotifier_Notify_Template(t *testing.T) {
+	var received Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	notifier := NewSlackNotifier("team", server.URL)
+	notifier.template, _ = ParseMessageTemplate("{{ .Chart }}")
+	err := notifier.Notify(Notification{Reports: []Report{testReport()}})
+
+	Equals(err, nil, t)
+	Equals(received.Text, "chart", t)
+	Equals(len(received.Attachments), 0, t)
+}
+
+func TestSlackButtons(t *testing.T) {
+	buttons := slackButtons(Entry{Sources: []string{"a", "b", "c", "d", "e", "f"}})
+
+	Equals(len(buttons), maxSlackButtons, t)
+	Equals(buttons[0].Text.Text, "Source 1", t)
+	Equals(len(slackButtons(Entry{})), 0, t)
+}
+
+func TestSlackEscape(t *testing.T) {
+	Equals(slackEscape("<a> & b"), "&lt;a&gt; &amp; b", t)
+}
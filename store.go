@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	StoreTypeMemory = "memory"
+	StoreTypeFile   = "file"
+	StoreTypeBolt   = "bolt"
+)
+
+// Store persists the state of the monitor, so it survives restarts. Values are
+// grouped in buckets and are stored as JSON.
+type Store interface {
+	Get(bucket, key string, value any) (bool, error)
+	Put(bucket, key string, value any) error
+	Delete(bucket, key string) error
+	Keys(bucket string) ([]string, error)
+	Close() error
+}
+
+type StateConfig struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+func (s StateConfig) Validate() error {
+	switch s.Type {
+	case "", StoreTypeMemory:
+		return nil
+	case StoreTypeFile, StoreTypeBolt:
+		if s.Path == "" {
+			return fmt.Errorf("state type %s requires a path", s.Type)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown state type %s", s.Type)
+	}
+}
+
+func OpenStore(config StateConfig) (Store, error) {
+	switch config.Type {
+	case "", StoreTypeMemory:
+		return NewMemoryStore(), nil
+	case StoreTypeFile:
+		return OpenFileStore(config.Path)
+	case StoreTypeBolt:
+		return OpenBoltStore(config.Path)
+	default:
+		return nil, fmt.Errorf("unknown state type %s", config.Type)
+	}
+}
+
+type MemoryStore struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string]json.RawMessage
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string]json.RawMessage)}
+}
+
+func (s *MemoryStore) Get(bucket, key string, value any) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, ok := s.buckets[bucket][key]
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(data, value)
+}
+
+func (s *MemoryStore) Put(bucket, key string, value any) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.put(bucket, key, value)
+}
+
+func (s *MemoryStore) put(bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if s.buckets[bucket] == nil {
+		s.buckets[bucket] = make(map[string]json.RawMessage)
+	}
+	s.buckets[bucket][key] = data
+	return nil
+}
+
+func (s *MemoryStore) Delete(bucket, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.buckets[bucket], key)
+	return nil
+}
+
+func (s *MemoryStore) Keys(bucket string) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make([]string, 0, len(s.buckets[bucket]))
+	for k := range s.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// FileStore keeps its state in memory and writes all of it to a single JSON
+// file after every change. It is meant for a file on a mounted volume.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &s.buckets)
+		if err != nil {
+			return nil, fmt.Errorf("could not read state file %s: %w", path, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Put(bucket, key string, value any) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.put(bucket, key, value)
+	if err != nil {
+		return err
+	}
+
+	return s.write()
+}
+
+func (s *FileStore) Delete(bucket, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.buckets[bucket][key]; !ok {
+		return nil
+	}
+
+	delete(s.buckets[bucket], key)
+	return s.write()
+}
+
+// write replaces the state file atomically, so a crash halfway through never
+// leaves a truncated file behind.
+func (s *FileStore) write() error {
+	data, err := json.MarshalIndent(s.buckets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// BoltStore keeps its state in an embedded bbolt key/value database.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open state database %s: %w", path, err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Get(bucket, key string, value any) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(data, value)
+	})
+
+	return found, err
+}
+
+func (s *BoltStore) Put(bucket, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(key), data)
+	})
+}
+
+func (s *BoltStore) Delete(bucket, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
+func (s *BoltStore) Keys(bucket string) ([]string, error) {
+	keys := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, _ []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+
+	return keys, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+func testStore(s Store, t *testing.T) {
+	var value string
+	found, err := s.Get("bucket", "missing", &value)
+	Equals(err, nil, t)
+	Equals(found, false, t)
+
+	Equals(s.Put("bucket", "b", "second"), nil, t)
+	Equals(s.Put("bucket", "a", "first"), nil, t)
+
+	found, err = s.Get("bucket", "a", &value)
+	Equals(err, nil, t)
+	Equals(found, true, t)
+	Equals(value, "first", t)
+
+	keys, err := s.Keys("bucket")
+	Equals(err, nil, t)
+	MapsEqual(keys, []string{"a", "b"}, t)
+
+	Equals(s.Delete("bucket", "b"), nil, t)
+	keys, _ = s.Keys("bucket")
+	MapsEqual(keys, []string{"a"}, t)
+
+	keys, _ = s.Keys("unknown")
+	MapsEqual(keys, []string{}, t)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(NewMemoryStore(), t)
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := OpenFileStore(filepath.Join(t.TempDir(), "state.json"))
+	Equals(err, nil, t)
+
+	testStore(s, t)
+}
+
+func TestFileStore_SurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, _ := OpenFileStore(path)
+	versions := map[ChartName]*semver.Version{"chart": semver.MustParse("1.2.3")}
+	Equals(s.Put(versionsBucket, "https://example.com/index.yaml", versions), nil, t)
+
+	reopened, err := OpenFileStore(path)
+	Equals(err, nil, t)
+
+	result := make(map[ChartName]*semver.Version)
+	found, err := reopened.Get(versionsBucket, "https://example.com/index.yaml", &result)
+	Equals(err, nil, t)
+	Equals(found, true, t)
+	Equals(result["chart"].String(), "1.2.3", t)
+}
+
+func TestBoltStore(t *testing.T) {
+	s, err := OpenBoltStore(filepath.Join(t.TempDir(), "state.db"))
+	Equals(err, nil, t)
+	defer s.Close()
+
+	testStore(s, t)
+}
+
+func TestBoltStore_SurvivesReopening(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	s, _ := OpenBoltStore(path)
+	Equals(s.Put("bucket", "key", 42), nil, t)
+	Equals(s.Close(), nil, t)
+
+	reopened, err := OpenBoltStore(path)
+	Equals(err, nil, t)
+	defer reopened.Close()
+
+	var result int
+	found, _ := reopened.Get("bucket", "key", &result)
+	Equals(found, true, t)
+	Equals(result, 42, t)
+}
+
+func TestStateConfig_Validate(t *testing.T) {
+	Equals(StateConfig{}.Validate(), nil, t)
+	Equals(StateConfig{Type: StoreTypeFile, Path: "state.json"}.Validate(), nil, t)
+	ErrorsEqual(StateConfig{Type: StoreTypeBolt}.Validate(), errors.New("state type bolt requires a path"), t)
+	ErrorsEqual(StateConfig{Type: "redis"}.Validate(), errors.New("unknown state type redis"), t)
+}
+
+func TestOpenStore_Memory(t *testing.T) {
+	s, err := OpenStore(StateConfig{})
+	Equals(err, nil, t)
+
+	_, ok := s.(*MemoryStore)
+	Equals(ok, true, t)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+
+// maxTeamsErrorLength limits how much of a response body ends up in an error.
+const maxTeamsErrorLength = 200
+
+// maxTeamsReports is the number of reports in a single card, which keeps the
+// card well below the 28 KB Teams accepts.
+const maxTeamsReports = 10
+
+// TeamsMessage is the payload of a Teams incoming webhook or workflow, which
+// wraps an Adaptive Card in an attachment.
+type TeamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+type TeamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+type AdaptiveCard struct {
+	Schema  string                `json:"$schema"`
+	Type    string                `json:"type"`
+	Version string                `json:"version"`
+	Body    []AdaptiveCardElement `json:"body"`
+	Actions []AdaptiveCardAction  `json:"actions,omitempty"`
+}
+
+// AdaptiveCardElement is either a TextBlock or a FactSet.
+type AdaptiveCardElement struct {
+	Type      string             `json:"type"`
+	Text      string             `json:"text,omitempty"`
+	Weight    string             `json:"weight,omitempty"`
+	Size      string             `json:"size,omitempty"`
+	Color     string             `json:"color,omitempty"`
+	Wrap      bool               `json:"wrap,omitempty"`
+	Separator bool               `json:"separator,omitempty"`
+	Facts     []AdaptiveCardFact `json:"facts,omitempty"`
+}
+
+type AdaptiveCardFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type AdaptiveCardAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// teamsColors are the Adaptive Card colours of the title of a report.
+var teamsColors = map[Bump]string{
+	BumpMajor: "Attention",
+	BumpMinor: "Warning",
+	BumpPatch: "Good",
+	BumpNone:  "Default",
+}
+
+// TeamsNotifier posts notifications as Adaptive Cards to a Microsoft Teams
+// incoming webhook or workflow.
+type TeamsNotifier struct {
+	name     string
+	url      string
+	client   *http.Client
+	template *MessageTemplate
+}
+
+func NewTeamsNotifier(name, url string) *TeamsNotifier {
+	return &TeamsNotifier{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: notifierTimeout},
+	}
+}
+
+func (n *TeamsNotifier) Name() string {
+	return n.name
+}
+
+func (n *TeamsNotifier) MaxReports() int {
+	return maxTeamsReports
+}
+
+func (n *TeamsNotifier) Notify(notification Notification) error {
+	card, err := n.card(notification)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(TeamsMessage{
+		Type:        "message",
+		Attachments: []TeamsAttachment{{ContentType: adaptiveCardContentType, Content: card}},
+	})
+	if err != nil {
+		return err
+	}
+
+	response, err := n.client.Post(n.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	err = teamsResponseError(response.StatusCode, string(body))
+	if err != nil && response.StatusCode == http.StatusTooManyRequests {
+		return &RetryAfterError{After: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()), Err: err}
+	}
+
+	return err
+}
+
+// teamsResponseError interprets the response of Teams. Connectors answer with a
+// body of "1" on success, workflows with an empty body. Connectors also answer
+// some failures, such as throttling, with a 200 and a description of the
+// error in the body, so any other body is treated as a failure.
+func teamsResponseError(status int, body string) error {
+	body = strings.TrimSpace(body)
+	if len(body) > maxTeamsErrorLength {
+		body = body[:maxTeamsErrorLength] + "..."
+	}
+
+	if status >= 300 {
+		if body == "" {
+			return statusError(status, fmt.Errorf("unexpected response code %d", status))
+		}
+		return statusError(status, fmt.Errorf("unexpected response code %d: %s", status, body))
+	}
+
+	if body != "" && body != "1" {
+		return fmt.Errorf("teams did not accept the message: %s", body)
+	}
+
+	return nil
+}
+
+func (n *TeamsNotifier) card(notification Notification) (AdaptiveCard, error) {
+	card := AdaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body:    make([]AdaptiveCardElement, 0),
+	}
+
+	if n.template != nil || notification.Text != "" {
+		text, err := n.template.Render(notification)
+		if err != nil {
+			return card, err
+		}
+
+		card.Body = append(card.Body, AdaptiveCardElement{Type: "TextBlock", Text: text, Wrap: true})
+		return card, nil
+	}
+
+	for i, r := range notification.Reports {
+		card.Body = append(card.Body, teamsReport(r, i > 0)...)
+		card.Actions = append(card.Actions, teamsActions(r)...)
+	}
+
+	return card, nil
+}
+
+func teamsReport(r Report, separator bool) []AdaptiveCardElement {
+	bump := r.Bump()
+	title := fmt.Sprintf("%s updated to %s", r.Chart, r.NewVersion)
+	if r.Preview {
+		title = fmt.Sprintf("%s has a new preview version %s", r.Chart, r.NewVersion)
+	}
+
+	repository := r.RepositoryName
+	if repository == "" {
+		repository = r.Repository
+	}
+
+	facts := []AdaptiveCardFact{
+		{Title: "Repository", Value: repository},
+		{Title: "Version", Value: fmt.Sprintf("%s → %s", r.PreviousVersion, r.NewVersion)},
+		{Title: "Bump", Value: bump.String()},
+	}
+	if len(r.NewVersions) > 1 {
+		facts = append(facts, AdaptiveCardFact{Title: "Released", Value: strings.Join(versionStrings(r.NewVersions), ", ")})
+	}
+	if len(r.Dependees) > 0 {
+		facts = append(facts, AdaptiveCardFact{Title: "Dependees", Value: strings.Join(r.Dependees, ", ")})
+	}
+
+	return []AdaptiveCardElement{
+		{Type: "TextBlock", Text: title, Weight: "Bolder", Size: "Medium", Color: teamsColors[bump], Wrap: true, Separator: separator},
+		{Type: "FactSet", Facts: facts},
+	}
+}
+
+// teamsActions links to the home and sources of the chart. The chart name is
+// part of the title, as the actions of all reports end up at the bottom of
+// the card.
+func teamsActions(r Report) []AdaptiveCardAction {
+	actions := make([]AdaptiveCardAction, 0)
+	if r.Metadata.Home != "" {
+		actions = append(actions, AdaptiveCardAction{Type: "Action.OpenUrl", Title: fmt.Sprintf("%s home", r.Chart), URL: r.Metadata.Home})
+	}
+
+	for _, source := range r.Metadata.Sources {
+		actions = append(actions, AdaptiveCardAction{Type: "Action.OpenUrl", Title: fmt.Sprintf("%s source", r.Chart), URL: source})
+	}
+
+	return actions
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeTeams answers like a Teams connector with the given status and body and
+// records the message it received.
+func fakeTeams(status int, body string, received *TeamsMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(received)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestTeamsNotifier_Notify(t *testing.T) {
+	var received TeamsMessage
+	server := fakeTeams(http.StatusOK, "1", &received)
+	defer server.Close()
+
+	report := testReport()
+	report.Metadata = Entry{Home: "https://example.com/chart", Sources: []string{"https://github.com/example/chart"}}
+	err := NewTeamsNotifier("team", server.URL).Notify(Notification{Reports: []Report{report, testReport()}})
+
+	Equals(err, nil, t)
+	Equals(received.Type, "message", t)
+	Equals(received.Attachments[0].ContentType, adaptiveCardContentType, t)
+
+	card := received.Attachments[0].Content
+	Equals(card.Type, "AdaptiveCard", t)
+	Equals(len(card.Body), 4, t)
+	Equals(card.Body[0].Text, "chart updated to 1.1.0", t)
+	Equals(card.Body[0].Color, "Warning", t)
+	Equals(card.Body[0].Separator, false, t)
+	Equals(card.Body[2].Separator, true, t)
+	MapsEqual(card.Body[1].Facts, []AdaptiveCardFact{
+		{Title: "Repository", Value: "https://example.com/index.yaml"},
+		{Title: "Version", Value: "1.0.0 → 1.1.0"},
+		{Title: "Bump", Value: "minor"},
+		{Title: "Released", Value: "1.0.1, 1.1.0"},
+		{Title: "Dependees", Value: "Example"},
+	}, t)
+	Equals(len(card.Actions), 2, t)
+	Equals(card.Actions[0].URL, "https://example.com/chart", t)
+}
+
+func TestTeamsNotifier_Notify_Text(t *testing.T) {
+	var received TeamsMessage
+	server := fakeTeams(http.StatusAccepted, "", &received)
+	defer server.Close()
+
+	err := NewTeamsNotifier("team", server.URL).Notify(Notification{Text: "started"})
+
+	Equals(err, nil, t)
+	Equals(len(received.Attachments[0].Content.Body), 1, t)
+	Equals(received.Attachments[0].Content.Body[0].Text, "started", t)
+}
+
+func TestTeamsNotifier_Notify_ErrorInBody(t *testing.T) {
+	server := fakeTeams(http.StatusOK, "Microsoft Teams endpoint returned HTTP error 429 with ContextId tcid=0", &TeamsMessage{})
+	defer server.Close()
+
+	err := NewTeamsNotifier("team", server.URL).Notify(Notification{Text: "started"})
+
+	Equals(err.Error(), "teams did not accept the message: Microsoft Teams endpoint returned HTTP error 429 with ContextId tcid=0", t)
+}
+
+func TestTeamsNotifier_Notify_Failure(t *testing.T) {
+	server := fakeTeams(http.StatusBadRequest, "Summary or Text is required.", &TeamsMessage{})
+	defer server.Close()
+
+	err := NewTeamsNotifier("team", server.URL).Notify(Notification{Text: "started"})
+
+	Equals(err.Error(), "unexpected response code 400: Summary or Text is required.", t)
+}
+
+func TestTeamsResponseError(t *testing.T) {
+	Equals(teamsResponseError(http.StatusOK, "1\n"), nil, t)
+	Equals(teamsResponseError(http.StatusAccepted, ""), nil, t)
+	Equals(teamsResponseError(http.StatusNotFound, "").Error(), "unexpected response code 404", t)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions that are available in message templates in
+// addition to the builtin ones.
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"versions": versionStrings,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// MessageTemplate renders the message of a report with a text/template. The
+// template is executed with the Report, so it has access to its fields and its
+// Bump method, such as {{ .Chart }} or {{ .Metadata.Home }}.
+type MessageTemplate struct {
+	template *template.Template
+}
+
+func ParseMessageTemplate(text string) (*MessageTemplate, error) {
+	t, err := template.New("message").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+
+	return &MessageTemplate{template: t}, nil
+}
+
+// Render returns the text of the notification. Plain text notifications and
+// notifications rendered without a template are returned as they are.
+func (m *MessageTemplate) Render(notification Notification) (string, error) {
+	if m == nil || notification.Text != "" {
+		return notification.String(), nil
+	}
+
+	messages := make([]string, len(notification.Reports))
+	for i, r := range notification.Reports {
+		message, err := m.RenderReport(r)
+		if err != nil {
+			return "", err
+		}
+		messages[i] = message
+	}
+
+	return strings.Join(messages, "\n\n"), nil
+}
+
+func (m *MessageTemplate) RenderReport(report Report) (string, error) {
+	if m == nil {
+		return report.Message(), nil
+	}
+
+	var buf bytes.Buffer
+	err := m.template.Execute(&buf, report)
+	if err != nil {
+		return "", fmt.Errorf("could not render message of %s: %w", report.Chart, err)
+	}
+
+	return buf.String(), nil
+}
+
+// StartInfo is what the start template is executed with.
+type StartInfo struct {
+	Started       time.Time
+	CheckInterval Duration
+	// Repositories contains the name, or else the URL, of every repository.
+	Repositories []string
+	// Config is the configuration as the default start message shows it.
+	Config string
+}
+
+func NewStartInfo(config Config, started time.Time) StartInfo {
+	repositories := make([]string, len(config.Repositories))
+	for i, r := range config.Repositories {
+		repositories[i] = config.RepositoryName(r.URL)
+	}
+
+	return StartInfo{
+		Started:       started,
+		CheckInterval: config.CheckInterval,
+		Repositories:  repositories,
+		Config:        config.String(),
+	}
+}
+
+// RenderStart returns the start message, which without a template contains
+// the time and the configuration.
+func (m *MessageTemplate) RenderStart(info StartInfo) (string, error) {
+	if m == nil {
+		return fmt.Sprintf("%s :: %s\n%s", info.Started.Format("2006-01-02 15:04:05"), "Helmchart monitor started", info.Config), nil
+	}
+
+	var buf bytes.Buffer
+	err := m.template.Execute(&buf, info)
+	if err != nil {
+		return "", fmt.Errorf("could not render start message: %w", err)
+	}
+
+	return buf.String(), nil
+}
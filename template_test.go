@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseMessageTemplate_Invalid(t *testing.T) {
+	_, err := ParseMessageTemplate("{{ .Chart ")
+
+	Equals(err != nil, true, t)
+}
+
+func TestMessageTemplate_RenderReport(t *testing.T) {
+	tmpl, _ := ParseMessageTemplate(`{{ .RepositoryName }}/{{ .Chart }} {{ .PreviousVersion }} -> {{ .NewVersion }} ({{ .Bump }}) [{{ join (versions .NewVersions) " " }}] {{ .Metadata.Home }} {{ join .Dependees ", " }}`)
+	report := testReport()
+	report.RepositoryName = "example"
+	report.Metadata = Entry{Version: "1.1.0", Home: "https://example.com/chart"}
+
+	text, err := tmpl.RenderReport(report)
+
+	Equals(err, nil, t)
+	Equals(text, "example/chart 1.0.0 -> 1.1.0 (minor) [1.0.1 1.1.0] https://example.com/chart Example", t)
+}
+
+func TestMessageTemplate_RenderReport_UnknownField(t *testing.T) {
+	tmpl, _ := ParseMessageTemplate("{{ .Unknown }}")
+
+	_, err := tmpl.RenderReport(testReport())
+
+	Equals(err != nil, true, t)
+}
+
+func TestMessageTemplate_Render(t *testing.T) {
+	tmpl, _ := ParseMessageTemplate("{{ .Chart }} {{ .NewVersion }}")
+
+	text, err := tmpl.Render(Notification{Reports: []Report{testReport(), testReport()}})
+	Equals(err, nil, t)
+	Equals(text, "chart 1.1.0\n\nchart 1.1.0", t)
+
+	text, err = tmpl.Render(Notification{Text: "started"})
+	Equals(err, nil, t)
+	Equals(text, "started", t)
+}
+
+func TestMessageTemplate_Render_NoTemplate(t *testing.T) {
+	var tmpl *MessageTemplate
+	n := Notification{Reports: []Report{testReport()}}
+
+	text, err := tmpl.Render(n)
+
+	Equals(err, nil, t)
+	Equals(text, n.String(), t)
+}
+
+func TestNewNotifier_Template(t *testing.T) {
+	n, err := NewNotifier(NotifierConfig{Name: "console", Type: NotifierTypeStdout, Template: "{{ .Chart }} is now {{ .NewVersion }}"})
+	Equals(err, nil, t)
+
+	var out bytes.Buffer
+	stdout := n.(*StdoutNotifier)
+	stdout.out = &out
+
+	Equals(stdout.Notify(Notification{Reports: []Report{testReport()}}), nil, t)
+	Equals(out.String(), "chart is now 1.1.0\n", t)
+}
+
+func TestMessageTemplate_RenderStart(t *testing.T) {
+	config := Config{
+		Repositories:  []Repository{{Name: "example", URL: "https://example.com/index.yaml"}, {URL: "https://other.example.com/index.yaml"}},
+		CheckInterval: Duration(time.Hour),
+	}
+	tmpl, _ := ParseMessageTemplate("Monitoring {{ join .Repositories \", \" }} every {{ .CheckInterval }} since {{ .Started.Format \"15:04\" }}")
+
+	text, err := tmpl.RenderStart(NewStartInfo(config, time.Date(2023, 1, 1, 9, 30, 0, 0, time.UTC)))
+
+	Equals(err, nil, t)
+	Equals(text, "Monitoring example, https://other.example.com/index.yaml every 1h0m0s since 09:30", t)
+}
+
+func TestMessageTemplate_RenderStart_WithoutTemplate(t *testing.T) {
+	var tmpl *MessageTemplate
+	config := DefaultConfig()
+
+	text, err := tmpl.RenderStart(NewStartInfo(config, time.Date(2023, 1, 1, 9, 30, 0, 0, time.UTC)))
+
+	Equals(err, nil, t)
+	Equals(text, "2023-01-01 09:30:00 :: Helmchart monitor started\n"+config.String(), t)
+}
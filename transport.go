@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// RepositoryTLS configures the TLS connections to a repository.
+type RepositoryTLS struct {
+	// CAFile contains PEM encoded certificates that are trusted in addition to
+	// the system certificates.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile contain the client certificate for mutual TLS.
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+func (t *RepositoryTLS) Validate() error {
+	if t == nil {
+		return nil
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return errors.New("tls requires both a cert_file and a key_file for client certificates")
+	}
+
+	return nil
+}
+
+func (t *RepositoryTLS) Config() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t == nil {
+		return config, nil
+	}
+
+	config.InsecureSkipVerify = t.InsecureSkipVerify
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s does not contain any certificates", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// HTTPClient builds the client that is used to fetch the repository, with its
+// TLS and proxy settings applied. Without a proxy the proxy environment
+// variables are used.
+func (r Repository) HTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig, err := r.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if r.Proxy != "" {
+		proxy, err := url.Parse(r.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{Transport: transport, CheckRedirect: r.checkRedirect}, nil
+}
+
+// checkRedirect removes the auth headers from redirects to other hosts. Go
+// only removes the Authorization and Cookie headers itself, so an API key
+// would otherwise be sent to the CDN an index redirects to.
+func (r Repository) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+
+	if r.Auth != nil && req.URL.Host != via[0].URL.Host {
+		for name := range r.Auth.Headers {
+			req.Header.Del(name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writePEM(t *testing.T, name, kind string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// clientCertificate creates a self-signed client certificate and returns the
+// certificate and the paths of its certificate and key files.
+func clientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "chart-version-monitor"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	certificate, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return certificate, writePEM(t, "client.crt", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+func tlsRepository(server *httptest.Server) Repository {
+	return Repository{URL: server.URL + "/fakechart.yaml", Charts: []Chart{{Name: "test"}}}
+}
+
+func TestRepositoryTLS_Validate(t *testing.T) {
+	var nilTLS *RepositoryTLS
+	Equals(nilTLS.Validate(), nil, t)
+	Equals((&RepositoryTLS{CertFile: "a", KeyFile: "b"}).Validate(), nil, t)
+	ErrorsEqual((&RepositoryTLS{CertFile: "a"}).Validate(), errors.New("tls requires both a cert_file and a key_file for client certificates"), t)
+}
+
+func TestRepository_HTTPClient_PrivateCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+
+	repo := tlsRepository(server)
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err != nil, true, t)
+
+	repo.TLS = &RepositoryTLS{CAFile: writePEM(t, "ca.crt", "CERTIFICATE", server.Certificate().Raw)}
+	contents, err := fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err, nil, t)
+	Equals(len(contents.Versions["test"]), 3, t)
+}
+
+func TestRepository_HTTPClient_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer server.Close()
+
+	repo := tlsRepository(server)
+	repo.TLS = &RepositoryTLS{InsecureSkipVerify: true}
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+
+	Equals(err, nil, t)
+}
+
+func TestRepository_HTTPClient_ClientCertificate(t *testing.T) {
+	certificate, certFile, keyFile := clientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certificate)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	repo := tlsRepository(server)
+	repo.TLS = &RepositoryTLS{InsecureSkipVerify: true}
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err != nil, true, t)
+
+	repo.TLS = &RepositoryTLS{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}
+	_, err = fetchRepositoryContents(context.Background(), nil, repo)
+	Equals(err, nil, t)
+}
+
+func TestRepository_HTTPClient_Proxy(t *testing.T) {
+	proxied := ""
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer proxy.Close()
+
+	repo := Repository{
+		URL:    "http://charts.example.com/index.yaml",
+		Charts: []Chart{{Name: "test"}},
+		Proxy:  proxy.URL,
+	}
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+
+	Equals(err, nil, t)
+	Equals(proxied, "http://charts.example.com/index.yaml", t)
+}
+
+func TestConfig_BuildHTTPClients_ReusesConnections(t *testing.T) {
+	var mutex sync.Mutex
+	connections := 0
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mutex.Lock()
+			connections++
+			mutex.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	config := Config{Repositories: []Repository{{URL: server.URL + "/fakechart.yaml", Charts: []Chart{{Name: "test"}}}}}
+	Equals(config.BuildHTTPClients(), nil, t)
+
+	for i := 0; i < 3; i++ {
+		_, err := fetchRepositoryContents(context.Background(), nil, config.Repositories[0])
+		Equals(err, nil, t)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	Equals(connections, 1, t)
+}
+
+func TestRepository_HTTPClient_RedirectDropsAuthHeaders(t *testing.T) {
+	cdnKey := "unset"
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnKey = r.Header.Get("X-Api-Key")
+		http.ServeFile(w, r, "fakechart.yaml")
+	}))
+	defer cdn.Close()
+
+	originKey := ""
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved.yaml" {
+			originKey = r.Header.Get("X-Api-Key")
+			http.Redirect(w, r, cdn.URL+"/fakechart.yaml", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/moved.yaml", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	repo := Repository{
+		URL:    origin.URL + "/index.yaml",
+		Charts: []Chart{{Name: "test"}},
+		Auth:   &RepositoryAuth{Headers: map[string]Secret{"x-api-key": {Value: "key"}}},
+	}
+	_, err := fetchRepositoryContents(context.Background(), nil, repo)
+
+	Equals(err, nil, t)
+	Equals(originKey, "key", t)
+	Equals(cdnKey, "", t)
+}
+
+func TestRepository_Validate_InvalidProxy(t *testing.T) {
+	repo := Repository{URL: "https://example.com", Charts: []Chart{{}}, Proxy: "not a proxy"}
+
+	ErrorsEqual(repo.Validate(), errors.New("repository https://example.com has invalid proxy not a proxy"), t)
+}
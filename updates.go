@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+// versionsBucket holds the highest version seen for every chart, keyed by
+// repository URL. previewsBucket does the same for prereleases that are
+// tracked separately.
+const versionsBucket = "versions"
+const previewsBucket = "previews"
+
+// Reporter stores reports, so they are delivered even when the monitor is
+// restarted before the notifiers were reached.
+type Reporter interface {
+	Report(report Report) error
+	CycleCompleted()
+}
+
+// checkRepositoriesForUpdates reports every update in the repositories. A nil
+// repository marks the end of a check cycle.
+func checkRepositoriesForUpdates(store Store, toCheck <-chan *RepositoryContents, reporter Reporter) {
+	for repo := range toCheck {
+		if repo == nil {
+			reporter.CycleCompleted()
+			continue
+		}
+
+		log.Println("Checking:", repo.URL)
+		now := time.Now()
+		reportUpdates(store, versionsBucket, repo, repo.Versions, false, now, reporter)
+		reportUpdates(store, previewsBucket, repo, repo.Previews, true, now, reporter)
+	}
+}
+
+// reportUpdates reports the updates of the versions and only then saves the
+// new highest versions. When a report could not be stored the highest versions
+// are kept, so the updates are reported again in the next check cycle instead
+// of being lost.
+func reportUpdates(store Store, bucket string, repo *RepositoryContents, versions map[ChartName]semver.Collection, preview bool, now time.Time, reporter Reporter) {
+	reports, highestVersions := findUpdates(store, bucket, repo.URL, versions)
+	for _, report := range reports {
+		report.Metadata, _ = repo.Entry(report.Chart, report.NewVersion)
+		report.DetectedAt = now
+		report.Preview = preview
+
+		err := reporter.Report(report)
+		if err != nil {
+			log.Println("Could not report", report.Chart, "from", repo.URL, "it will be reported again", err)
+			return
+		}
+	}
+
+	saveHighestVersions(store, bucket, repo.URL, highestVersions)
+}
+
+// findUpdates compares the versions with the highest versions in the bucket of
+// the store and returns a report for every chart that has a newer version,
+// together with the new highest versions. The highest versions are nil when
+// they did not change.
+func findUpdates(store Store, bucket string, repository string, versions map[ChartName]semver.Collection) ([]Report, map[ChartName]*semver.Version) {
+	reports := make([]Report, 0)
+	if len(versions) == 0 {
+		return reports, nil
+	}
+
+	highestVersions := make(map[ChartName]*semver.Version)
+	_, err := store.Get(bucket, repository, &highestVersions)
+	if err != nil {
+		log.Println("Could not load highest versions for", repository, err)
+	}
+
+	changed := false
+	for chartName, chartVersions := range versions {
+		if len(chartVersions) == 0 {
+			continue
+		}
+
+		highestVersion := chartVersions[len(chartVersions)-1]
+
+		// Without a previous version there is nothing to compare to. When the
+		// highest version went down, for instance because the constraint of
+		// the chart changed, the new highest version becomes the baseline.
+		currentVersion, ok := highestVersions[chartName]
+		if !ok || highestVersion.LessThan(currentVersion) {
+			highestVersions[chartName] = highestVersion
+			changed = true
+			continue
+		}
+
+		if currentVersion.LessThan(highestVersion) {
+			highestVersions[chartName] = highestVersion
+			changed = true
+			reports = append(reports, Report{
+				Repository:      repository,
+				Chart:           chartName,
+				PreviousVersion: currentVersion,
+				NewVersion:      highestVersion,
+				NewVersions:     VersionsNewerThan(chartVersions, currentVersion),
+			})
+		}
+	}
+
+	if !changed {
+		return reports, nil
+	}
+
+	return reports, highestVersions
+}
+
+func saveHighestVersions(store Store, bucket string, repository string, highestVersions map[ChartName]*semver.Version) {
+	if highestVersions == nil {
+		return
+	}
+
+	err := store.Put(bucket, repository, highestVersions)
+	if err != nil {
+		log.Println("Could not save highest versions for", repository, err)
+	}
+}
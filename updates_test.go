@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Masterminds/semver"
+)
+
+func versions(v ...string) semver.Collection {
+	collection := make(semver.Collection, len(v))
+	for i, s := range v {
+		collection[i] = semver.MustParse(s)
+	}
+
+	return collection
+}
+
+// checkVersions finds the updates of chart in repo and saves the new highest
+// versions, like reportUpdates does once the reports are stored.
+func checkVersions(store Store, v ...string) []Report {
+	reports, highest := findUpdates(store, versionsBucket, "repo", map[ChartName]semver.Collection{"chart": versions(v...)})
+	saveHighestVersions(store, versionsBucket, "repo", highest)
+
+	return reports
+}
+
+type fakeReporter struct {
+	reports []Report
+	cycles  int
+	err     error
+}
+
+func (f *fakeReporter) Report(report Report) error {
+	if f.err != nil {
+		return f.err
+	}
+
+	f.reports = append(f.reports, report)
+	return nil
+}
+
+func (f *fakeReporter) CycleCompleted() {
+	f.cycles++
+}
+
+func TestFindUpdates_FirstCheckIsBaseline(t *testing.T) {
+	store := NewMemoryStore()
+
+	reports := checkVersions(store, "1.0.0", "1.1.0")
+
+	Equals(len(reports), 0, t)
+
+	highest := make(map[ChartName]*semver.Version)
+	found, _ := store.Get(versionsBucket, "repo", &highest)
+	Equals(found, true, t)
+	Equals(highest["chart"].String(), "1.1.0", t)
+}
+
+func TestFindUpdates_ReportsEveryNewerVersion(t *testing.T) {
+	store := NewMemoryStore()
+	checkVersions(store, "4.5.2")
+
+	reports := checkVersions(store, "4.5.2", "4.5.3", "4.5.4", "4.6.0")
+
+	Equals(len(reports), 1, t)
+	Equals(reports[0].Repository, "repo", t)
+	Equals(reports[0].Chart, ChartName("chart"), t)
+	Equals(reports[0].PreviousVersion.String(), "4.5.2", t)
+	Equals(reports[0].NewVersion.String(), "4.6.0", t)
+	MapsEqual(versionStrings(reports[0].NewVersions), []string{"4.5.3", "4.5.4", "4.6.0"}, t)
+}
+
+func TestFindUpdates_NothingNew(t *testing.T) {
+	store := NewMemoryStore()
+	checkVersions(store, "1.0.0")
+
+	reports := checkVersions(store, "1.0.0")
+
+	Equals(len(reports), 0, t)
+}
+
+func TestFindUpdates_LowerHighestVersionBecomesBaseline(t *testing.T) {
+	store := NewMemoryStore()
+	checkVersions(store, "5.0.0")
+	checkVersions(store, "1.2.0")
+
+	reports := checkVersions(store, "1.2.0", "1.2.1")
+
+	Equals(len(reports), 1, t)
+	Equals(reports[0].NewVersion.String(), "1.2.1", t)
+}
+
+func TestCheckRepositoriesForUpdates_Previews(t *testing.T) {
+	store := NewMemoryStore()
+	toCheck := make(chan *RepositoryContents, 2)
+	reporter := &fakeReporter{}
+
+	toCheck <- &RepositoryContents{URL: "repo", Versions: map[ChartName]semver.Collection{"chart": versions("1.0.0")}, Previews: map[ChartName]semver.Collection{"chart": versions("2.0.0-rc.1")}}
+	toCheck <- &RepositoryContents{URL: "repo", Versions: map[ChartName]semver.Collection{"chart": versions("1.0.0")}, Previews: map[ChartName]semver.Collection{"chart": versions("2.0.0-rc.1", "2.0.0-rc.2")}}
+	close(toCheck)
+	checkRepositoriesForUpdates(store, toCheck, reporter)
+
+	Equals(len(reporter.reports), 1, t)
+	Equals(reporter.reports[0].Preview, true, t)
+	Equals(reporter.reports[0].NewVersion.String(), "2.0.0-rc.2", t)
+}
+
+func TestCheckRepositoriesForUpdates_Metadata(t *testing.T) {
+	store := NewMemoryStore()
+	toCheck := make(chan *RepositoryContents, 2)
+	reporter := &fakeReporter{}
+
+	entries := map[ChartName][]Entry{"chart": {{Version: "1.0.0"}, {Version: "1.1.0", Home: "https://example.com/chart"}}}
+	toCheck <- &RepositoryContents{URL: "repo", Versions: map[ChartName]semver.Collection{"chart": versions("1.0.0")}}
+	toCheck <- &RepositoryContents{URL: "repo", Entries: entries, Versions: map[ChartName]semver.Collection{"chart": versions("1.0.0", "1.1.0")}}
+	close(toCheck)
+	checkRepositoriesForUpdates(store, toCheck, reporter)
+
+	Equals(reporter.reports[0].Metadata.Home, "https://example.com/chart", t)
+}
+
+func TestCheckRepositoriesForUpdates_KeepsHighestVersionWhenReportingFails(t *testing.T) {
+	store := NewMemoryStore()
+	checkVersions(store, "1.0.0")
+	failing := &fakeReporter{err: errors.New("disk full")}
+
+	toCheck := make(chan *RepositoryContents, 1)
+	toCheck <- &RepositoryContents{URL: "repo", Versions: map[ChartName]semver.Collection{"chart": versions("1.0.0", "1.1.0")}}
+	close(toCheck)
+	checkRepositoriesForUpdates(store, toCheck, failing)
+
+	reports := checkVersions(store, "1.0.0", "1.1.0")
+	Equals(len(reports), 1, t)
+	Equals(reports[0].NewVersion.String(), "1.1.0", t)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSchemaVersion is the version of the payload of the webhook notifier.
+// Fields are only ever added within a version.
+const WebhookSchemaVersion = 1
+
+const (
+	WebhookEventChartUpdates = "chart_updates"
+	WebhookEventMessage      = "message"
+)
+
+const (
+	WebhookIdempotencyHeader = "Idempotency-Key"
+	WebhookTimestampHeader   = "X-CVM-Timestamp"
+	WebhookSignatureHeader   = "X-CVM-Signature-256"
+)
+
+// WebhookNotifier posts notifications as generic JSON to any URL. When a
+// secret is configured, the payload is signed so receivers can verify it.
+type WebhookNotifier struct {
+	name     string
+	url      string
+	client   *http.Client
+	template *MessageTemplate
+	secret   *Secret
+	now      func() time.Time
+}
+
+type WebhookPayload struct {
+	Version int `json:"version"`
+	// ID is the idempotency key of the event. It only depends on the contents
+	// of the event, so receivers can drop events that are delivered again.
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp time.Time       `json:"timestamp"`
+	Text      string          `json:"text"`
+	Reports   []WebhookReport `json:"reports"`
+}
+
+type WebhookReport struct {
+	ID              string    `json:"id"`
+	Repository      string    `json:"repository"`
+	RepositoryName  string    `json:"repository_name"`
+	Chart           ChartName `json:"chart"`
+	PreviousVersion string    `json:"previous_version"`
+	NewVersion      string    `json:"new_version"`
+	NewVersions     []string  `json:"new_versions"`
+	Bump            string    `json:"bump"`
+	Dependees       []string  `json:"dependees"`
+	Preview         bool      `json:"preview"`
+	DetectedAt      time.Time `json:"detected_at"`
+}
+
+func NewWebhookNotifier(name, url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		name:   name,
+		url:    url,
+		client: &http.Client{Timeout: notifierTimeout},
+		now:    time.Now,
+	}
+}
+
+func (w *WebhookNotifier) Name() string {
+	return w.name
+}
+
+func (w *WebhookNotifier) Notify(notification Notification) error {
+	text, err := w.template.Render(notification)
+	if err != nil {
+		return err
+	}
+
+	payload := WebhookPayload{
+		Version:   WebhookSchemaVersion,
+		Type:      WebhookEventChartUpdates,
+		Timestamp: w.now().UTC(),
+		Text:      text,
+		Reports:   make([]WebhookReport, len(notification.Reports)),
+	}
+	if notification.Text != "" {
+		payload.Type = WebhookEventMessage
+	}
+
+	ids := make([]string, len(notification.Reports))
+	for i, r := range notification.Reports {
+		ids[i] = r.ID()
+		payload.Reports[i] = WebhookReport{
+			ID:              ids[i],
+			Repository:      r.Repository,
+			RepositoryName:  r.RepositoryName,
+			Chart:           r.Chart,
+			PreviousVersion: r.PreviousVersion.String(),
+			NewVersion:      r.NewVersion.String(),
+			NewVersions:     versionStrings(r.NewVersions),
+			Bump:            r.Bump().String(),
+			Dependees:       r.Dependees,
+			Preview:         r.Preview,
+			DetectedAt:      r.DetectedAt.UTC(),
+		}
+	}
+	payload.ID = hashOf(payload.Type, text, strings.Join(ids, ","))
+	if payload.Type == WebhookEventMessage {
+		// Messages are not identified by their text, which may be repeated.
+		nonce := notification.Nonce
+		if nonce == "" {
+			nonce = newNonce()
+		}
+		payload.ID = hashOf(payload.Type, text, nonce)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIdempotencyHeader, payload.ID)
+
+	if w.secret.IsSet() {
+		secret, err := w.secret.Resolve()
+		if err != nil {
+			return err
+		}
+
+		timestamp := strconv.FormatInt(payload.Timestamp.Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, timestamp, body))
+	}
+
+	return doRequest(w.client, req)
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 of the timestamp, a dot and
+// the body. Including the timestamp allows receivers to reject replays.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashOf returns a hex encoded SHA-256 of the parts.
+func hashOf(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	var received WebhookPayload
+	var idempotencyKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(r.Header.Get(WebhookSignatureHeader), "", t)
+		idempotencyKey = r.Header.Get(WebhookIdempotencyHeader)
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	detected := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	report := testReport()
+	report.RepositoryName = "example"
+	report.DetectedAt = detected
+	notifier := NewWebhookNotifier("automation", server.URL)
+	notifier.now = func() time.Time { return detected.Add(time.Minute) }
+	err := notifier.Notify(Notification{Reports: []Report{report}})
+
+	Equals(err, nil, t)
+	Equals(received.Version, WebhookSchemaVersion, t)
+	Equals(received.Type, WebhookEventChartUpdates, t)
+	Equals(received.ID, idempotencyKey, t)
+	Equals(received.Timestamp.Equal(detected.Add(time.Minute)), true, t)
+	Equals(received.Text, report.Message(), t)
+	MapsEqual(received.Reports, []WebhookReport{{
+		ID:              report.ID(),
+		Repository:      "https://example.com/index.yaml",
+		RepositoryName:  "example",
+		Chart:           "chart",
+		PreviousVersion: "1.0.0",
+		NewVersion:      "1.1.0",
+		NewVersions:     []string{"1.0.1", "1.1.0"},
+		Bump:            "minor",
+		Dependees:       []string{"Example"},
+		DetectedAt:      detected,
+	}}, t)
+}
+
+func TestWebhookNotifier_Notify_IdempotencyKey(t *testing.T) {
+	keys := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(WebhookIdempotencyHeader))
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier("automation", server.URL)
+	other := testReport()
+	other.Chart = "other"
+
+	_ = notifier.Notify(Notification{Reports: []Report{testReport()}})
+	_ = notifier.Notify(Notification{Reports: []Report{testReport()}})
+	_ = notifier.Notify(Notification{Reports: []Report{other}})
+
+	Equals(len(keys), 3, t)
+	Equals(keys[0], keys[1], t)
+	Equals(keys[0] != keys[2], true, t)
+}
+
+func TestWebhookNotifier_Notify_MessageIDIsUnique(t *testing.T) {
+	keys := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(WebhookIdempotencyHeader))
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier("automation", server.URL)
+	started := NewTextNotification("started")
+
+	_ = notifier.Notify(started)
+	_ = notifier.Notify(started)
+	_ = notifier.Notify(NewTextNotification("started"))
+	_ = notifier.Notify(Notification{Text: "started"})
+
+	Equals(len(keys), 4, t)
+	Equals(keys[0], keys[1], t)
+	Equals(keys[0] != keys[2], true, t)
+	Equals(keys[3] != keys[0] && keys[3] != keys[2], true, t)
+}
+
+func TestWebhookNotifier_Notify_Signed(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier("automation", server.URL)
+	notifier.secret = &Secret{Value: "s3cr3t"}
+	notifier.now = func() time.Time { return time.Unix(1672574400, 0) }
+	err := notifier.Notify(Notification{Text: "started"})
+
+	Equals(err, nil, t)
+	Equals(header.Get(WebhookTimestampHeader), "1672574400", t)
+	Equals(header.Get(WebhookSignatureHeader), "sha256="+SignWebhook("s3cr3t", "1672574400", body), t)
+}
+
+func TestWebhookNotifier_Notify_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier("automation", server.URL).Notify(Notification{Text: "started"})
+
+	Equals(err.Error(), "unexpected response code 500", t)
+}
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '1672574400.{}' | openssl dgst -sha256 -hmac s3cr3t
+	Equals(SignWebhook("s3cr3t", "1672574400", []byte("{}")), "61b13a43792d662dc00ea3fff4f5a157c1108e9fd8fa38b7a167e344468d782e", t)
+}
+
+func TestReport_ID(t *testing.T) {
+	preview := testReport()
+	preview.Preview = true
+
+	Equals(testReport().ID(), testReport().ID(), t)
+	Equals(testReport().ID() != preview.ID(), true, t)
+}
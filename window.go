@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeliveryWindow is the recurring period in which a notifier may deliver,
+// such as "every weekday 08:00-18:00 Europe/Amsterdam". The days are written
+// like the days of a Schedule and are the days the window opens. A window that
+// ends before it starts, 22:00-06:00 for instance, ends the next day.
+type DeliveryWindow struct {
+	Days     map[time.Weekday]bool
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+func ParseDeliveryWindow(s string) (*DeliveryWindow, error) {
+	days, clocks, location, err := parseRecurring(s, "delivery window", "every weekday 08:00-18:00 Europe/Amsterdam")
+	if err != nil {
+		return nil, err
+	}
+
+	startClock, endClock, found := strings.Cut(clocks, "-")
+	start, startErr := time.Parse("15:04", startClock)
+	end, endErr := time.Parse("15:04", endClock)
+	if !found || startErr != nil || endErr != nil || start.Equal(end) {
+		return nil, fmt.Errorf("invalid delivery window %q: the times should look like 08:00-18:00", s)
+	}
+
+	return &DeliveryWindow{
+		Days:     days,
+		Start:    sinceMidnight(start),
+		End:      sinceMidnight(end),
+		Location: location,
+	}, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// NextOpen returns t when the window is open at t, or else the moment the
+// window opens next. A nil window is always open.
+func (w *DeliveryWindow) NextOpen(t time.Time) time.Time {
+	if w == nil {
+		return t
+	}
+
+	local := t.In(w.Location)
+	// Start a day early, as the window of yesterday may still be open.
+	for i := -1; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		if !w.Days[day.Weekday()] {
+			continue
+		}
+
+		opens := w.at(day, w.Start)
+		closes := w.at(day, w.End)
+		if w.End < w.Start {
+			closes = w.at(day.AddDate(0, 0, 1), w.End)
+		}
+
+		if t.Before(opens) {
+			return opens
+		}
+		if t.Before(closes) {
+			return t
+		}
+	}
+
+	// Only reachable without days, which ParseDeliveryWindow does not allow.
+	return t
+}
+
+// at returns the moment on the day of t that is offset after midnight.
+func (w *DeliveryWindow) at(t time.Time, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, w.Location)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeliveryWindow(t *testing.T) {
+	window, err := ParseDeliveryWindow("every weekday 08:00-18:30 Europe/Amsterdam")
+
+	Equals(err, nil, t)
+	Equals(len(window.Days), 5, t)
+	Equals(window.Start, 8*time.Hour, t)
+	Equals(window.End, 18*time.Hour+30*time.Minute, t)
+	Equals(window.Location.String(), "Europe/Amsterdam", t)
+}
+
+func TestParseDeliveryWindow_Invalid(t *testing.T) {
+	for _, s := range []string{"", "every weekday", "every weekday 08:00", "every weekday 08:00-08:00", "every someday 08:00-18:00", "every day 08:00-18:00 Nowhere/Here"} {
+		_, err := ParseDeliveryWindow(s)
+		Equals(err != nil, true, t)
+	}
+}
+
+func TestDeliveryWindow_NextOpen(t *testing.T) {
+	window, _ := ParseDeliveryWindow("every weekday 08:00-18:00 Europe/Amsterdam")
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+
+	// Tuesday 3 January 2023.
+	open := time.Date(2023, 1, 3, 12, 0, 0, 0, amsterdam)
+	Equals(window.NextOpen(open), open, t)
+
+	night := time.Date(2023, 1, 3, 3, 0, 0, 0, amsterdam)
+	Equals(window.NextOpen(night).Equal(time.Date(2023, 1, 3, 8, 0, 0, 0, amsterdam)), true, t)
+
+	evening := time.Date(2023, 1, 3, 18, 0, 0, 0, amsterdam)
+	Equals(window.NextOpen(evening).Equal(time.Date(2023, 1, 4, 8, 0, 0, 0, amsterdam)), true, t)
+
+	// Friday evening waits for monday.
+	friday := time.Date(2023, 1, 6, 20, 0, 0, 0, amsterdam)
+	Equals(window.NextOpen(friday).Equal(time.Date(2023, 1, 9, 8, 0, 0, 0, amsterdam)), true, t)
+}
+
+func TestDeliveryWindow_NextOpen_Overnight(t *testing.T) {
+	window, _ := ParseDeliveryWindow("every day 22:00-06:00 UTC")
+
+	afterMidnight := time.Date(2023, 1, 3, 2, 0, 0, 0, time.UTC)
+	Equals(window.NextOpen(afterMidnight), afterMidnight, t)
+
+	afternoon := time.Date(2023, 1, 3, 14, 0, 0, 0, time.UTC)
+	Equals(window.NextOpen(afternoon), time.Date(2023, 1, 3, 22, 0, 0, 0, time.UTC), t)
+}
+
+func TestDeliveryWindow_NextOpen_Nil(t *testing.T) {
+	var window *DeliveryWindow
+	now := time.Now()
+
+	Equals(window.NextOpen(now), now, t)
+}